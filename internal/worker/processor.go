@@ -1,16 +1,25 @@
 package worker
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"log"
 	"net"
+	"time"
 
 	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/fakedns"
 	"xdp-dns/pkg/filter"
+	"xdp-dns/pkg/forwarder"
 	"xdp-dns/pkg/metrics"
 )
 
+// nowUnix 返回当前 Unix 时间, 供 dns.TTLPolicy 的抖动窗口计算使用
+func nowUnix() int64 {
+	return time.Now().Unix()
+}
+
 const (
 	EthernetHeaderLen = 14
 	IPv4HeaderLen     = 20
@@ -100,9 +109,10 @@ func extractDNSPayload(data []byte) ([]byte, *PacketInfo, error) {
 	return data[dnsOffset:dnsEnd], info, nil
 }
 
-// handleAction 处理过滤动作
+// handleAction 处理过滤动作。ttlPolicy 对 Redirect/FakeDNS 等合成应答的 TTL 做
+// 抖动与夹取 (见 dns.TTLPolicy), 零值 TTLPolicy 等价于不做任何处理。
 func (p *Pool) handleAction(pkt Packet, msg *dns.Message, action filter.Action,
-	rule *filter.Rule, pktInfo *PacketInfo, metricsCollector *metrics.Collector) {
+	rule *filter.Rule, pktInfo *PacketInfo, metricsCollector *metrics.Collector, fakeDNS *fakedns.Manager, ttlPolicy dns.TTLPolicy) {
 
 	switch action {
 	case filter.ActionAllow:
@@ -126,11 +136,12 @@ func (p *Pool) handleAction(pkt Packet, msg *dns.Message, action filter.Action,
 	case filter.ActionRedirect:
 		// 生成重定向响应
 		if rule != nil && rule.RedirectIP != nil {
+			ttl := ttlPolicy.Apply(rule.RedirectTTL, pktInfo.SrcIP, msg.GetQueryDomain(), msg.GetQueryType(), nowUnix())
 			var response []byte
 			if msg.GetQueryType() == dns.TypeAAAA {
-				response = dns.BuildAAAAResponse(msg, rule.RedirectIP, rule.RedirectTTL)
+				response = dns.BuildAAAAResponse(msg, rule.RedirectIP, ttl)
 			} else {
-				response = dns.BuildAResponse(msg, rule.RedirectIP, rule.RedirectTTL)
+				response = dns.BuildAResponse(msg, rule.RedirectIP, ttl)
 			}
 			if response != nil {
 				p.sendResponse(pkt, response, pktInfo)
@@ -148,10 +159,188 @@ func (p *Pool) handleAction(pkt Packet, msg *dns.Message, action filter.Action,
 		if metricsCollector != nil {
 			metricsCollector.IncLogged()
 		}
+
+	case filter.ActionRewrite:
+		if rule != nil && rule.Rewrite != nil {
+			if response := buildRewriteResponse(msg, rule.Rewrite); response != nil {
+				p.sendResponse(pkt, response, pktInfo)
+			}
+		}
+		if metricsCollector != nil {
+			metricsCollector.IncRedirected()
+		}
+		if rule != nil {
+			log.Printf("Rewritten: %s (rule: %s)", msg.GetQueryDomain(), rule.ID)
+		}
+
+	case filter.ActionFakeDNS:
+		if response := buildFakeDNSResponse(msg, fakeDNS, rule, ttlPolicy, pktInfo.SrcIP); response != nil {
+			p.sendResponse(pkt, response, pktInfo)
+		}
+		if metricsCollector != nil {
+			metricsCollector.IncRedirected()
+		}
+
+	case filter.ActionForward:
+		if response := buildForwardResponse(pkt, rule); response != nil {
+			p.sendResponse(pkt, response, pktInfo)
+			if metricsCollector != nil {
+				metricsCollector.IncAllowed()
+			}
+		} else if metricsCollector != nil {
+			metricsCollector.IncDropped()
+		}
+
+	case filter.ActionAnswer:
+		if rule == nil || rule.LocalAnswer == nil {
+			if metricsCollector != nil {
+				metricsCollector.IncDropped()
+			}
+			break
+		}
+		if response := buildLocalAnswerResponse(msg, rule.LocalAnswer); response != nil {
+			p.sendResponse(pkt, response, pktInfo)
+		}
+		if metricsCollector != nil {
+			metricsCollector.IncRedirected()
+		}
 	}
 }
 
+// buildForwardResponse 把 pkt 里的原始 DNS 查询转发给 rule.Forwarders 里的一组上游
+// 并发竞速 (见 pkg/forwarder), 返回第一个通过校验的响应; rule 没有配置上游、DNS
+// 负载提取失败或所有上游都失败时返回 nil
+func buildForwardResponse(pkt Packet, rule *filter.Rule) []byte {
+	if rule == nil || len(rule.Forwarders) == 0 {
+		return nil
+	}
+
+	query, _, err := extractDNSPayload(pkt.Data)
+	if err != nil {
+		log.Printf("Forward: failed to extract DNS payload: %v", err)
+		return nil
+	}
+
+	ups := make([]forwarder.Upstream, len(rule.Forwarders))
+	for i, u := range rule.Forwarders {
+		ups[i] = forwarder.Upstream{Addr: u.Addr, StartDelay: u.StartDelay}
+	}
+
+	fwd := forwarder.New(ups, forwarder.DefaultTimeout)
+	response, err := fwd.Forward(context.Background(), query)
+	if err != nil {
+		log.Printf("Forward: upstream query failed: %v", err)
+		return nil
+	}
+	return response
+}
+
+// buildLocalAnswerResponse 按查询类型从 rule 的本地权威记录集 (ActionAnswer 专用)
+// 合成响应, 没有对应记录的查询类型应答 NODATA
+func buildLocalAnswerResponse(query *dns.Message, local *filter.LocalAnswer) []byte {
+	switch {
+	case query.GetQueryType() == dns.TypeAAAA && len(local.AAAA) > 0:
+		return dns.BuildAAAAResponse(query, local.NextAAAA(), local.TTL)
+	case query.GetQueryType() == dns.TypeA && len(local.A) > 0:
+		return dns.BuildAResponse(query, local.NextA(), local.TTL)
+	case query.GetQueryType() == dns.TypePTR && local.PTR != "":
+		return dns.BuildPTRResponse(query, local.PTR, local.TTL)
+	default:
+		return dns.BuildRewriteResponse(query, dns.RCodeNoError, true, nil)
+	}
+}
+
+// buildFakeDNSResponse 为匹配 action: fakedns 的查询从地址池分配 (或复用) 一个
+// IP 并合成 A/AAAA 响应; qtype 不是 A/AAAA, 或没有配置 fakeDNS 子系统时不作应答,
+// 留给规则链后续步骤或上游处理
+func buildFakeDNSResponse(query *dns.Message, fakeDNS *fakedns.Manager, rule *filter.Rule, ttlPolicy dns.TTLPolicy, srcIP string) []byte {
+	if fakeDNS == nil {
+		return nil
+	}
+
+	qtype := query.GetQueryType()
+	if qtype != dns.TypeA && qtype != dns.TypeAAAA {
+		return nil
+	}
+
+	ttl := uint32(300)
+	if rule != nil && rule.RedirectTTL != 0 {
+		ttl = rule.RedirectTTL
+	}
+	ttl = ttlPolicy.Apply(ttl, srcIP, query.GetQueryDomain(), qtype, nowUnix())
+
+	ip, err := fakeDNS.Allocate(query.GetQueryDomain(), qtype)
+	if err != nil {
+		log.Printf("fakedns: allocation failed for %s: %v", query.GetQueryDomain(), err)
+		return nil
+	}
+
+	if qtype == dns.TypeAAAA {
+		return dns.BuildAAAAResponse(query, ip, ttl)
+	}
+	return dns.BuildAResponse(query, ip, ttl)
+}
+
+// buildRewriteResponse 把一条 $dnsrewrite 规则 (filter.Rewrite) 按查询类型合成为
+// 响应报文。CNAME 只生成重写记录本身, 不在这里链式解析目标域名, 客户端需要
+// 自行再发起一次查询来解析 CNAME 目标。
+func buildRewriteResponse(query *dns.Message, rw *filter.Rewrite) []byte {
+	var rcode uint16
+	if rw.RCode != nil {
+		rcode = *rw.RCode
+	}
+
+	if rw.NoData || rcode != dns.RCodeNoError {
+		return dns.BuildRewriteResponse(query, rcode, rw.NoData, nil)
+	}
+
+	qtype := query.GetQueryType()
+	var records []dns.RewriteRecord
+
+	if rw.CNAME != "" {
+		records = append(records, dns.RewriteRecord{Type: dns.TypeCNAME, TTL: 300, RData: dns.EncodeCNAMERData(rw.CNAME)})
+	}
+
+	switch qtype {
+	case dns.TypeAAAA:
+		for _, a := range rw.AAAA {
+			records = append(records, dns.RewriteRecord{Type: dns.TypeAAAA, TTL: a.TTL, RData: a.IP.To16()})
+		}
+	default:
+		for _, a := range rw.A {
+			records = append(records, dns.RewriteRecord{Type: dns.TypeA, TTL: a.TTL, RData: a.IP.To4()})
+		}
+	}
+
+	for _, mx := range rw.MX {
+		records = append(records, dns.RewriteRecord{Type: dns.TypeMX, TTL: mx.TTL, RData: dns.EncodeMXRData(mx.Preference, mx.Exchange)})
+	}
+	for _, txt := range rw.TXT {
+		records = append(records, dns.RewriteRecord{Type: dns.TypeTXT, TTL: txt.TTL, RData: dns.EncodeTXTRData(txt.Text)})
+	}
+	for _, ptr := range rw.PTR {
+		records = append(records, dns.RewriteRecord{Type: dns.TypePTR, TTL: ptr.TTL, RData: dns.EncodePTRRData(ptr.Target)})
+	}
+	for _, srv := range rw.SRV {
+		records = append(records, dns.RewriteRecord{Type: dns.TypeSRV, TTL: srv.TTL, RData: dns.EncodeSRVRData(srv.Priority, srv.Weight, srv.Port, srv.Target)})
+	}
+	for _, svcb := range rw.HTTPS {
+		records = append(records, dns.RewriteRecord{Type: dns.TypeHTTPS, TTL: svcb.TTL, RData: dns.EncodeSVCBRData(svcb.Priority, svcb.Target)})
+	}
+	for _, svcb := range rw.SVCB {
+		records = append(records, dns.RewriteRecord{Type: dns.TypeSVCB, TTL: svcb.TTL, RData: dns.EncodeSVCBRData(svcb.Priority, svcb.Target)})
+	}
+
+	return dns.BuildRewriteResponse(query, dns.RCodeNoError, false, records)
+}
+
 // sendResponse 发送响应
+//
+// 这里没有实现 pkg/listener.Responder, 因为该接口的 Respond(srcIP, []byte) 签名
+// 是为已经知道对端地址的连接型/无状态传输 (TCP、TLS、HTTP) 设计的, 而 AF_XDP 发送
+// 响应需要复用接收时同一个 Packet 的描述符和原始以太网/IP/UDP 头 (见上面的
+// buildResponsePacket), 没有可丢弃的等价物。若要让 XDP 路径也满足 Responder, 需要
+// 先把 TX 描述符的获取/归还封装成一个不依赖 Packet 的类型。
 func (p *Pool) sendResponse(pkt Packet, dnsResponse []byte, pktInfo *PacketInfo) {
 	responsePkt, err := buildResponsePacket(pkt.Data, dnsResponse, pktInfo)
 	if err != nil {
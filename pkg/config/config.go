@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"xdp-dns/pkg/dns"
 )
 
 // Config 主配置结构
@@ -20,16 +22,57 @@ type Config struct {
 	RulesPath  string        `yaml:"rules_path"`  // 过滤规则路径
 	Metrics    MetricsConfig `yaml:"metrics"`     // 监控配置
 	Logging    LoggingConfig `yaml:"logging"`     // 日志配置
+
+	// RuleSources 声明一组可插拔规则来源 (hosts/AdGuard/dnsmasq/域名列表), 由
+	// filter.Engine 周期性拉取并与 RulesPath 指向的 YAML 规则合并, 见 pkg/filter/rulesource.go
+	RuleSources []RuleSourceConfig `yaml:"rule_sources"`
+
+	// RulesHotReload 为 true 时监听 RulesPath 所在目录, 文件被覆盖/替换后自动
+	// 重新加载, 见 pkg/filter/watcher.go
+	RulesHotReload bool `yaml:"rules_hot_reload"`
+
+	// RPZSources 声明一组 RPZ (Response Policy Zone) 区域, 由 pkg/rpz.Loader 周期性
+	// 拉取 (HTTPS+ETag 或 AXFR) 并转换为 filter.Rule 安装进规则引擎
+	RPZSources []RPZSourceConfig `yaml:"rpz_sources"`
+
+	// Dnstap 配置 dnstap 风格的决策日志输出 (pkg/dnstap), 通过 filter.Engine.AddSink
+	// 挂接, 与规则文件里的 log_sampling 配合使用
+	Dnstap DnstapConfig `yaml:"dnstap"`
+}
+
+// RPZSourceConfig 描述单个 RPZ 区域来源
+type RPZSourceConfig struct {
+	Zone         string        `yaml:"zone"`          // 区域名, 用于生成规则 ID 前缀
+	URL          string        `yaml:"url"`           // HTTPS 区域文件地址 (与 AXFRAddr 二选一)
+	AXFRAddr     string        `yaml:"axfr_addr"`     // AXFR 服务器地址 (与 URL 二选一, 非空时优先使用)
+	PollInterval time.Duration `yaml:"poll_interval"` // 重新拉取的轮询周期, <=0 时使用 10 分钟默认值
+}
+
+// DnstapConfig 配置决策日志的 dnstap sink
+type DnstapConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Sink     string `yaml:"sink"`      // "file" | "socket"
+	Path     string `yaml:"path"`      // sink=file 时的输出路径
+	MaxBytes int64  `yaml:"max_bytes"` // sink=file 时单个文件的滚动大小上限
+	Network  string `yaml:"network"`   // sink=socket 时的网络类型, 如 "unix"、"tcp"
+	Address  string `yaml:"address"`   // sink=socket 时的目标地址
+}
+
+// RuleSourceConfig 描述单个可插拔规则来源
+type RuleSourceConfig struct {
+	Type         string        `yaml:"type"` // "hosts" | "adguard" | "dnsmasq" | "domainlist"
+	Path         string        `yaml:"path"`
+	PollInterval time.Duration `yaml:"poll_interval"` // 重新拉取的轮询周期, 0 表示只在启动时加载一次
 }
 
 // XDPConfig AF_XDP Socket配置
 type XDPConfig struct {
-	NumFrames          int `yaml:"num_frames"`           // 帧数量
-	FrameSize          int `yaml:"frame_size"`           // 帧大小
-	FillRingNumDescs   int `yaml:"fill_ring_size"`       // Fill Ring大小
-	CompletionRingNumDescs int `yaml:"comp_ring_size"`   // Completion Ring大小
-	RxRingNumDescs     int `yaml:"rx_ring_size"`         // RX Ring大小
-	TxRingNumDescs     int `yaml:"tx_ring_size"`         // TX Ring大小
+	NumFrames              int `yaml:"num_frames"`     // 帧数量
+	FrameSize              int `yaml:"frame_size"`     // 帧大小
+	FillRingNumDescs       int `yaml:"fill_ring_size"` // Fill Ring大小
+	CompletionRingNumDescs int `yaml:"comp_ring_size"` // Completion Ring大小
+	RxRingNumDescs         int `yaml:"rx_ring_size"`   // RX Ring大小
+	TxRingNumDescs         int `yaml:"tx_ring_size"`   // TX Ring大小
 }
 
 // WorkerConfig Worker配置
@@ -40,10 +83,58 @@ type WorkerConfig struct {
 
 // DNSConfig DNS配置
 type DNSConfig struct {
-	ListenPorts     []uint16      `yaml:"listen_ports"`     // 监听端口
-	UpstreamServers []string      `yaml:"upstream_servers"` // 上游DNS服务器
-	CacheSize       int           `yaml:"cache_size"`       // 缓存大小
-	CacheTTL        time.Duration `yaml:"cache_ttl"`        // 缓存TTL
+	ListenPorts      []uint16          `yaml:"listen_ports"`       // 监听端口
+	UpstreamServers  []string          `yaml:"upstream_servers"`   // 上游DNS服务器
+	CacheSize        int               `yaml:"cache_size"`         // 缓存大小
+	CacheTTL         time.Duration     `yaml:"cache_ttl"`          // 缓存TTL
+	FakeDNS          FakeDNSConfig     `yaml:"fakedns"`            // Fake DNS 地址池配置
+	Listeners        []ListenerConfig  `yaml:"listeners"`          // 加密 DNS 前端监听器 (DoH/DoT/DoQ), 见 pkg/listener
+	TTLJitterPercent int               `yaml:"ttl_jitter_percent"` // 合成/重定向应答的 TTL 抖动幅度 (%), 见 dns.TTLPolicy
+	MinClientTTL     uint32            `yaml:"min_client_ttl"`     // 下发给客户端的 TTL 下限, 0 表示不设下限
+	MaxClientTTL     uint32            `yaml:"max_client_ttl"`     // 下发给客户端的 TTL 上限, 0 表示不设上限
+	TCPFallback      TCPFallbackConfig `yaml:"tcp_fallback"`       // 普通 DNS-over-TCP 兜底监听, 见 pkg/tcpserver
+}
+
+// TCPFallbackConfig 配置 pkg/tcpserver 提供的普通 TCP:53 兜底监听 (供收到 TC=1
+// 的客户端重试, 以及大应答/AXFR 等原本就要求 TCP 的查询), 复用与 AF_XDP fast path
+// 相同的 hybrid.Processor 决策逻辑
+type TCPFallbackConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Listen      string        `yaml:"listen"`       // 监听地址, 如 ":53"
+	IdleTimeout time.Duration `yaml:"idle_timeout"` // 连接空闲超时, 0 使用 Server 默认值
+	MaxConns    int           `yaml:"max_conns"`    // 最大并发连接数, 0 使用 Server 默认值
+}
+
+// TTLPolicy 把配置转换为 dns.TTLPolicy, 供 worker.handleAction 在构建响应前调用
+func (c DNSConfig) TTLPolicy() dns.TTLPolicy {
+	return dns.TTLPolicy{
+		JitterPercent: c.TTLJitterPercent,
+		MinTTL:        c.MinClientTTL,
+		MaxTTL:        c.MaxClientTTL,
+	}
+}
+
+// ListenerConfig 描述一个加密 DNS 前端监听器 (pkg/listener, pkg/dns/tcp)
+type ListenerConfig struct {
+	Protocol             string   `yaml:"protocol"`               // "doh" | "dot" | "doq"
+	Listen               string   `yaml:"listen"`                 // 监听地址, 如 ":853"
+	CertFile             string   `yaml:"cert_file"`              // TLS 证书路径 (dot/doh/doq 均需要)
+	KeyFile              string   `yaml:"key_file"`               // TLS 私钥路径
+	ALPN                 []string `yaml:"alpn"`                   // ALPN 协议标识, 如 ["dot"]、["h2","http/1.1"]
+	HTTP2                bool     `yaml:"http2"`                  // doh: 是否启用 HTTP/2
+	HTTP3                bool     `yaml:"http3"`                  // doh: 是否启用 HTTP/3 (需要 QUIC, 见 pkg/listener/doq.go)
+	MaxConcurrentStreams uint32   `yaml:"max_concurrent_streams"` // doh/doq: 单连接最大并发流数
+}
+
+// FakeDNSConfig Fake DNS 子系统配置 (详见 pkg/fakedns), 为匹配 action: fakedns
+// 规则的查询即时分配一个私有地址并记录 domain<->ip 映射
+type FakeDNSConfig struct {
+	Enabled     bool          `yaml:"enabled"`      // 是否启用
+	IPv4Pools   []string      `yaml:"ipv4_pools"`   // IPv4 地址池 CIDR 列表
+	IPv6Pools   []string      `yaml:"ipv6_pools"`   // IPv6 地址池 CIDR 列表
+	PoolSize    int           `yaml:"pool_size"`    // domain<->ip 映射表最大容量
+	TTL         time.Duration `yaml:"ttl"`          // 映射存活时间
+	GracePeriod time.Duration `yaml:"grace_period"` // 映射过期后到 IP 被允许复用之间的保留期
 }
 
 // MetricsConfig 监控配置
@@ -68,12 +159,12 @@ func DefaultConfig() *Config {
 		QueueCount: 1,
 		BPFPath:    "bpf/xdp_dns_filter_bpfel.o",
 		XDP: XDPConfig{
-			NumFrames:          4096,
-			FrameSize:          2048,
-			FillRingNumDescs:   2048,
+			NumFrames:              4096,
+			FrameSize:              2048,
+			FillRingNumDescs:       2048,
 			CompletionRingNumDescs: 2048,
-			RxRingNumDescs:     2048,
-			TxRingNumDescs:     2048,
+			RxRingNumDescs:         2048,
+			TxRingNumDescs:         2048,
 		},
 		Workers: WorkerConfig{
 			NumWorkers: 0, // 使用CPU核心数
@@ -84,8 +175,28 @@ func DefaultConfig() *Config {
 			UpstreamServers: []string{"8.8.8.8:53", "8.8.4.4:53"},
 			CacheSize:       10000,
 			CacheTTL:        5 * time.Minute,
+			FakeDNS: FakeDNSConfig{
+				Enabled:     false,
+				IPv4Pools:   []string{"198.18.0.0/15"},
+				IPv6Pools:   []string{"fc00::/18"},
+				PoolSize:    65536,
+				TTL:         time.Hour,
+				GracePeriod: time.Minute,
+			},
+			TTLJitterPercent: 0,
+			MinClientTTL:     0,
+			MaxClientTTL:     0,
+			TCPFallback: TCPFallbackConfig{
+				Enabled: false,
+				Listen:  ":53",
+			},
+		},
+		RulesPath:      "configs/rules.yaml",
+		RulesHotReload: false,
+		Dnstap: DnstapConfig{
+			Enabled: false,
+			Sink:    "file",
 		},
-		RulesPath: "configs/rules.yaml",
 		Metrics: MetricsConfig{
 			Enabled: true,
 			Listen:  ":9090",
@@ -153,4 +264,3 @@ func (c *Config) Save(path string) error {
 
 	return nil
 }
-
@@ -0,0 +1,280 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+)
+
+// maxDomainNameWireOctets 是一个域名编码后在报文里允许占用的最大字节数 (RFC 1035 §3.1)
+const maxDomainNameWireOctets = 255
+
+// maxCompressionOffset 是压缩指针 14 位偏移量能表示的最大值 (RFC 1035 §4.1.4),
+// 起始偏移达到或超过这个值的后缀不再值得被记入压缩表, 因为指针本身也指不到它
+const maxCompressionOffset = 1 << 14
+
+var (
+	// ErrNilMessage Pack/PackInto 的 msg 参数为 nil
+	ErrNilMessage = errors.New("dns: cannot pack a nil message")
+	// ErrLabelTooLong 域名中存在长度超过 63 字节的标签
+	ErrLabelTooLong = errors.New("dns: label exceeds 63 bytes")
+	// ErrNameTooLong 域名编码后超过 maxDomainNameWireOctets
+	ErrNameTooLong = errors.New("dns: encoded domain name exceeds 255 octets")
+	// ErrBufferTooSmall PackInto 提供的缓冲区容量不足以容纳编码后的消息
+	ErrBufferTooSmall = errors.New("dns: destination buffer is too small")
+)
+
+// Builder 把 *Message 编码回 DNS 线路格式的字节序列, 是 Parser 的逆操作
+//
+// 编码域名时会做 RFC 1035 §4.1.4 的压缩: 对同一次 Pack 过程中已经写过的域名后缀
+// (NAME/RR 等顶层名字字段, 不包括已经编码进 RDATA 里的内部名字, 那些由调用方在
+// 构造 ResourceRecord.RData 时自行决定是否压缩) 记录其在报文里的起始偏移, 之后
+// 再遇到相同后缀时直接写一个 0xC0/0x3FFF 指针, 不重复编码标签。
+type Builder struct {
+	buf      []byte
+	fixedCap bool // true 时 buf 容量固定 (PackInto), 写入超出容量返回 ErrBufferTooSmall 而不是扩容
+	suffixes map[string]uint16
+}
+
+// newBuilder 创建一个从 buf[:0] 开始写入的 Builder; fixedCap 为 true 时不允许扩容
+func newBuilder(buf []byte, fixedCap bool) *Builder {
+	return &Builder{
+		buf:      buf[:0],
+		fixedCap: fixedCap,
+		suffixes: make(map[string]uint16, 8),
+	}
+}
+
+// writeBytes 把 p 追加到 buf, fixedCap 模式下超出容量时返回 ErrBufferTooSmall 而不扩容
+func (b *Builder) writeBytes(p []byte) error {
+	if b.fixedCap && len(b.buf)+len(p) > cap(b.buf) {
+		return ErrBufferTooSmall
+	}
+	b.buf = append(b.buf, p...)
+	return nil
+}
+
+func (b *Builder) writeUint16(v uint16) error {
+	var tmp [2]byte
+	binary.BigEndian.PutUint16(tmp[:], v)
+	return b.writeBytes(tmp[:])
+}
+
+func (b *Builder) writeUint32(v uint32) error {
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], v)
+	return b.writeBytes(tmp[:])
+}
+
+// writeName 编码一个域名, 尽可能复用本次 Pack 过程中已经出现过的后缀
+func (b *Builder) writeName(name string) error {
+	labels := splitDomainName(strings.TrimSuffix(name, "."))
+	for _, label := range labels {
+		if len(label) > 63 {
+			return ErrLabelTooLong
+		}
+	}
+
+	encoded := 0
+	for i, label := range labels {
+		suffix := strings.ToLower(strings.Join(labels[i:], "."))
+
+		if off, ok := b.suffixes[suffix]; ok {
+			encoded += 2
+			if encoded > maxDomainNameWireOctets {
+				return ErrNameTooLong
+			}
+			return b.writeBytes([]byte{0xC0 | byte(off>>8), byte(off)})
+		}
+
+		if len(b.buf) < maxCompressionOffset {
+			b.suffixes[suffix] = uint16(len(b.buf))
+		}
+
+		if err := b.writeBytes([]byte{byte(len(label))}); err != nil {
+			return err
+		}
+		if err := b.writeBytes([]byte(label)); err != nil {
+			return err
+		}
+		encoded += 1 + len(label)
+		if encoded > maxDomainNameWireOctets {
+			return ErrNameTooLong
+		}
+	}
+
+	encoded++
+	if encoded > maxDomainNameWireOctets {
+		return ErrNameTooLong
+	}
+	return b.writeBytes([]byte{0})
+}
+
+func (b *Builder) writeQuestion(q Question) error {
+	if err := b.writeName(q.Name); err != nil {
+		return err
+	}
+	if err := b.writeUint16(q.QType); err != nil {
+		return err
+	}
+	return b.writeUint16(q.QClass)
+}
+
+// writeRR 写入一条资源记录, RDLENGTH 按 rr.RData 的实际长度重新计算 (不信任调用方
+// 填写的 rr.RDLength); RDATA 本身按原样写入, 其中出现的域名 (如 CNAME/PTR 的目标)
+// 不参与本次压缩表, 由调用方在编码 RDATA 时自行决定
+func (b *Builder) writeRR(rr ResourceRecord) error {
+	if err := b.writeName(rr.Name); err != nil {
+		return err
+	}
+	if err := b.writeUint16(rr.Type); err != nil {
+		return err
+	}
+	if err := b.writeUint16(rr.Class); err != nil {
+		return err
+	}
+	if err := b.writeUint32(rr.TTL); err != nil {
+		return err
+	}
+	if err := b.writeUint16(uint16(len(rr.RData))); err != nil {
+		return err
+	}
+	return b.writeBytes(rr.RData)
+}
+
+// writeOPT 把解析得到的 EDNS 信息编码回一条 OPT 伪记录 (RFC 6891 §6.1.2/§6.1.3),
+// 是 parseOPTRecord 的逆操作
+func (b *Builder) writeOPT(edns *EDNS) error {
+	var rdata []byte
+	for _, opt := range edns.Options {
+		rdata = append(rdata, encodeEDNSOption(opt.Code, opt.Data)...)
+	}
+
+	ttl := uint32(edns.ExtendedRCode)<<24 | uint32(edns.Version)<<16
+	if edns.DO {
+		ttl |= 0x8000
+	}
+
+	return b.writeRR(ResourceRecord{
+		Name:  "",
+		Type:  TypeOPT,
+		Class: edns.UDPPayloadSize,
+		TTL:   ttl,
+		RData: rdata,
+	})
+}
+
+// packMessage 是 Pack/PackInto 共用的编码实现
+func packMessage(buf []byte, msg *Message, fixedCap bool) ([]byte, error) {
+	if msg == nil {
+		return nil, ErrNilMessage
+	}
+
+	arcount := len(msg.Additional)
+	if msg.EDNS != nil {
+		arcount++
+	}
+
+	b := newBuilder(buf, fixedCap)
+
+	header := msg.Header
+	header.QDCount = uint16(len(msg.Questions))
+	header.ANCount = uint16(len(msg.Answers))
+	header.NSCount = uint16(len(msg.Authority))
+	header.ARCount = uint16(arcount)
+
+	if err := b.writeUint16(header.ID); err != nil {
+		return nil, err
+	}
+	if err := b.writeUint16(header.Flags); err != nil {
+		return nil, err
+	}
+	if err := b.writeUint16(header.QDCount); err != nil {
+		return nil, err
+	}
+	if err := b.writeUint16(header.ANCount); err != nil {
+		return nil, err
+	}
+	if err := b.writeUint16(header.NSCount); err != nil {
+		return nil, err
+	}
+	if err := b.writeUint16(header.ARCount); err != nil {
+		return nil, err
+	}
+
+	for _, q := range msg.Questions {
+		if err := b.writeQuestion(q); err != nil {
+			return nil, err
+		}
+	}
+	for _, rr := range msg.Answers {
+		if err := b.writeRR(rr); err != nil {
+			return nil, err
+		}
+	}
+	for _, rr := range msg.Authority {
+		if err := b.writeRR(rr); err != nil {
+			return nil, err
+		}
+	}
+	for _, rr := range msg.Additional {
+		if err := b.writeRR(rr); err != nil {
+			return nil, err
+		}
+	}
+	if msg.EDNS != nil {
+		if err := b.writeOPT(msg.EDNS); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.buf, nil
+}
+
+// Pack 把 msg 编码为一段新分配的 DNS 报文字节
+func Pack(msg *Message) ([]byte, error) {
+	return packMessage(nil, msg, false)
+}
+
+// PackInto 把 msg 编码进 buf (从 buf[:0] 开始写), 复用调用方在热路径上预先分配好
+// 的缓冲区而不产生新的分配; buf 的容量不足以容纳编码结果时返回 ErrBufferTooSmall,
+// 不会像 append 那样静默扩容到一块新底层数组 (那样的话调用方凭返回的长度已经拿不到
+// 正确的数据了)。成功时返回写入的字节数, 调用方取 buf[:n] 即为编码结果。
+func PackInto(buf []byte, msg *Message) (int, error) {
+	out, err := packMessage(buf, msg, true)
+	if err != nil {
+		return 0, err
+	}
+	return len(out), nil
+}
+
+// BuildQuery 构造一条发往上游的查询报文: RD=1, 随机事务 ID, 单个问题
+func BuildQuery(name string, qtype uint16) ([]byte, error) {
+	msg := &Message{
+		Header:    Header{ID: uint16(rand.Intn(1 << 16)), Flags: 0x0100}, // RD=1
+		Questions: []Question{{Name: name, QType: qtype, QClass: ClassIN}},
+	}
+	return Pack(msg)
+}
+
+// BuildResponse 基于 query 构造一条携带 answers 的应答报文: QR=1, 回显 RD,
+// RA=1, RCODE=NOERROR, 是 Parse 对响应报文解析的逆操作
+func BuildResponse(query *Message, answers []ResourceRecord) ([]byte, error) {
+	if query == nil || len(query.Questions) == 0 {
+		return nil, ErrNilMessage
+	}
+
+	flags := uint16(0x8000)              // QR=1
+	flags |= query.Header.Flags & 0x0100 // 回显 RD
+	flags |= 0x0080                      // RA=1
+	flags |= RCodeNoError
+
+	msg := &Message{
+		Header:    Header{ID: query.Header.ID, Flags: flags},
+		Questions: query.Questions,
+		Answers:   answers,
+		EDNS:      query.EDNS,
+	}
+	return Pack(msg)
+}
@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPack_RoundTripsThroughParser(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 0x55AA, Flags: 0x8180},
+		Questions: []Question{{Name: "www.example.com", QType: TypeA, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "www.example.com", Type: TypeA, Class: ClassIN, TTL: 60, RData: []byte{1, 2, 3, 4}},
+		},
+	}
+
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	parsed, err := NewParser().Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse(Pack(msg)) error = %v", err)
+	}
+	if parsed.Header.ID != 0x55AA {
+		t.Errorf("ID = %#x, want 0x55AA", parsed.Header.ID)
+	}
+	if len(parsed.Answers) != 1 || parsed.Answers[0].Name != "www.example.com" {
+		t.Fatalf("Answers = %+v", parsed.Answers)
+	}
+}
+
+func TestPack_CompressesRepeatedSuffix(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeNS, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeNS, Class: ClassIN, TTL: 60, RData: EncodeCNAMERData("ns1.example.com")},
+			{Name: "example.com", Type: TypeNS, Class: ClassIN, TTL: 60, RData: EncodeCNAMERData("ns2.example.com")},
+		},
+	}
+
+	compressed, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	// "example" 的原始标签字节只应该出现在: 问题部分一次, 以及两条 RDATA (NS
+	// 记录的 RDATA 由 EncodeCNAMERData 独立编码, 不参与本次压缩表) 各一次。
+	// 两条 Answer 自身的 NAME 字段都是 "example.com", 如果压缩指针生效, 它们
+	// 都应该被替换成 2 字节指针而不再重复出现 "example" 这几个字节。
+	wantOccurrences := 1 + len(msg.Answers)
+	if got := strings.Count(string(compressed), "example"); got != wantOccurrences {
+		t.Fatalf("raw \"example\" label occurrences = %d, want %d (compression pointer should have replaced the 2 Answer NAME fields)", got, wantOccurrences)
+	}
+
+	parsed, err := NewParser().Parse(compressed)
+	if err != nil {
+		t.Fatalf("Parse(compressed) error = %v", err)
+	}
+	if len(parsed.Answers) != 2 || parsed.Answers[1].Name != "example.com" {
+		t.Fatalf("Answers = %+v", parsed.Answers)
+	}
+}
+
+func TestBuilder_WriteName_RejectsOverlongLabel(t *testing.T) {
+	b := newBuilder(nil, false)
+	longLabel := strings.Repeat("a", 64)
+	if err := b.writeName(longLabel + ".example.com"); err != ErrLabelTooLong {
+		t.Fatalf("writeName() error = %v, want ErrLabelTooLong", err)
+	}
+}
+
+func TestPackInto_FixedCapacityTooSmall(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeA, QClass: ClassIN}},
+	}
+
+	buf := make([]byte, 0, 4) // 明显不够装下一个完整查询
+	if _, err := PackInto(buf, msg); err != ErrBufferTooSmall {
+		t.Fatalf("PackInto() error = %v, want ErrBufferTooSmall", err)
+	}
+}
+
+func TestPackInto_ReusesProvidedBuffer(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeA, QClass: ClassIN}},
+	}
+
+	buf := make([]byte, 0, 512)
+	n, err := PackInto(buf, msg)
+	if err != nil {
+		t.Fatalf("PackInto() error = %v", err)
+	}
+	if _, err := NewParser().Parse(buf[:n]); err != nil {
+		t.Fatalf("Parse(buf[:n]) error = %v", err)
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	raw, err := BuildQuery("example.com", TypeAAAA)
+	if err != nil {
+		t.Fatalf("BuildQuery() error = %v", err)
+	}
+
+	msg, err := NewParser().Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse(BuildQuery()) error = %v", err)
+	}
+	if msg.IsResponse() {
+		t.Error("BuildQuery() should not set QR")
+	}
+	if msg.GetQueryDomain() != "example.com" || msg.GetQueryType() != TypeAAAA {
+		t.Errorf("question = %+v", msg.Questions)
+	}
+}
+
+func TestBuildResponse(t *testing.T) {
+	query := &Message{
+		Header:    Header{ID: 0x9999, Flags: 0x0100},
+		Questions: []Question{{Name: "example.com", QType: TypeA, QClass: ClassIN}},
+	}
+	answers := []ResourceRecord{
+		{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 30, RData: []byte{9, 9, 9, 9}},
+	}
+
+	raw, err := BuildResponse(query, answers)
+	if err != nil {
+		t.Fatalf("BuildResponse() error = %v", err)
+	}
+
+	msg, err := NewParser().Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse(BuildResponse()) error = %v", err)
+	}
+	if !msg.IsResponse() || msg.Header.ID != 0x9999 {
+		t.Fatalf("response header = %+v", msg.Header)
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("Answers = %+v, want 1", msg.Answers)
+	}
+}
@@ -0,0 +1,135 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// buildTestQueryWithECS 构建一个携带 OPT 伪记录和 ECS 选项的查询包
+func buildTestQueryWithECS(domain string, family uint16, sourcePrefixLen uint8, addr []byte) []byte {
+	packet := []byte{
+		0x12, 0x34, // ID
+		0x01, 0x00, // Flags
+		0x00, 0x01, // QDCount = 1
+		0x00, 0x00, // ANCount = 0
+		0x00, 0x00, // NSCount = 0
+		0x00, 0x01, // ARCount = 1 (OPT)
+	}
+
+	start := 0
+	for i := 0; i <= len(domain); i++ {
+		if i == len(domain) || domain[i] == '.' {
+			packet = append(packet, byte(i-start))
+			packet = append(packet, []byte(domain[start:i])...)
+			start = i + 1
+		}
+	}
+	packet = append(packet, 0, 0x00, 0x01, 0x00, 0x01) // 结束符 + TYPE A + CLASS IN
+
+	packet = append(packet, 0) // OPT NAME = root
+
+	typeBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(typeBuf, TypeOPT)
+	packet = append(packet, typeBuf...)
+
+	classBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(classBuf, 4096)
+	packet = append(packet, classBuf...)
+
+	packet = append(packet, 0, 0, 0, 0) // TTL (no DO, ext rcode/version 0)
+
+	ecsData := make([]byte, 4+len(addr))
+	binary.BigEndian.PutUint16(ecsData[0:2], family)
+	ecsData[2] = sourcePrefixLen
+	ecsData[3] = 0 // SCOPE PREFIX-LENGTH, 查询中恒为 0
+	copy(ecsData[4:], addr)
+
+	var rdata []byte
+	codeBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(codeBuf, EDNSOptionECS)
+	lenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(lenBuf, uint16(len(ecsData)))
+	rdata = append(rdata, codeBuf...)
+	rdata = append(rdata, lenBuf...)
+	rdata = append(rdata, ecsData...)
+
+	rdlenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(rdlenBuf, uint16(len(rdata)))
+	packet = append(packet, rdlenBuf...)
+	packet = append(packet, rdata...)
+
+	return packet
+}
+
+func TestParser_ParsesECS(t *testing.T) {
+	packet := buildTestQueryWithECS("example.com", ecsFamilyIPv4, 24, []byte{192, 0, 2, 0})
+
+	p := NewParser()
+	msg, err := p.Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if msg.EDNS == nil {
+		t.Fatal("Parse() did not populate EDNS")
+	}
+
+	ecs := msg.EDNS.ECS()
+	if ecs == nil {
+		t.Fatal("EDNS.ECS() = nil, want parsed option")
+	}
+	if ecs.SourcePrefixLen != 24 {
+		t.Errorf("SourcePrefixLen = %d, want 24", ecs.SourcePrefixLen)
+	}
+	if !ecs.Address.Equal(net.IPv4(192, 0, 2, 0)) {
+		t.Errorf("Address = %v, want 192.0.2.0", ecs.Address)
+	}
+}
+
+func TestBuildAResponse_EchoesECSWithZeroScope(t *testing.T) {
+	packet := buildTestQueryWithECS("example.com", ecsFamilyIPv4, 24, []byte{192, 0, 2, 0})
+
+	p := NewParser()
+	query, err := p.Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	resp := BuildAResponse(query, net.ParseIP("1.2.3.4"), 300)
+	respMsg, err := p.Parse(resp)
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+
+	if respMsg.EDNS == nil {
+		t.Fatal("response has no OPT record echoed back")
+	}
+
+	ecs := respMsg.EDNS.ECS()
+	if ecs == nil {
+		t.Fatal("response did not echo ECS option")
+	}
+	if ecs.SourcePrefixLen != 24 {
+		t.Errorf("echoed SourcePrefixLen = %d, want 24 (unchanged from query)", ecs.SourcePrefixLen)
+	}
+	if ecs.ScopePrefixLen != 0 {
+		t.Errorf("echoed ScopePrefixLen = %d, want 0 for a synthesized answer", ecs.ScopePrefixLen)
+	}
+	if !ecs.Address.Equal(net.IPv4(192, 0, 2, 0)) {
+		t.Errorf("echoed Address = %v, want 192.0.2.0", ecs.Address)
+	}
+}
+
+func TestEDNS_ECSNilWithoutOption(t *testing.T) {
+	packet := buildTestQueryWithOPT("example.com", 4096, false, nil)
+
+	p := NewParser()
+	msg, err := p.Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if ecs := msg.EDNS.ECS(); ecs != nil {
+		t.Errorf("ECS() = %+v, want nil when no ECS option present", ecs)
+	}
+}
@@ -0,0 +1,177 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// buildTestQueryWithOPT 构建一个携带 OPT 伪记录的查询包 (含可选 COOKIE 选项)
+func buildTestQueryWithOPT(domain string, udpSize uint16, do bool, clientCookie []byte) []byte {
+	packet := []byte{
+		0x12, 0x34, // ID
+		0x01, 0x00, // Flags
+		0x00, 0x01, // QDCount = 1
+		0x00, 0x00, // ANCount = 0
+		0x00, 0x00, // NSCount = 0
+		0x00, 0x01, // ARCount = 1 (OPT)
+	}
+
+	start := 0
+	for i := 0; i <= len(domain); i++ {
+		if i == len(domain) || domain[i] == '.' {
+			packet = append(packet, byte(i-start))
+			packet = append(packet, []byte(domain[start:i])...)
+			start = i + 1
+		}
+	}
+	packet = append(packet, 0, 0x00, 0x01, 0x00, 0x01) // 结束符 + TYPE A + CLASS IN
+
+	// OPT 伪记录: 根域名 + TYPE=41 + CLASS=udpSize + TTL(ext rcode/version/DO) + RDATA
+	packet = append(packet, 0) // root name
+
+	typeBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(typeBuf, TypeOPT)
+	packet = append(packet, typeBuf...)
+
+	classBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(classBuf, udpSize)
+	packet = append(packet, classBuf...)
+
+	var ttl uint32
+	if do {
+		ttl |= 0x8000
+	}
+	ttlBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(ttlBuf, ttl)
+	packet = append(packet, ttlBuf...)
+
+	var rdata []byte
+	if len(clientCookie) > 0 {
+		codeBuf := make([]byte, 2)
+		binary.BigEndian.PutUint16(codeBuf, EDNSOptionCookie)
+		lenBuf := make([]byte, 2)
+		binary.BigEndian.PutUint16(lenBuf, uint16(len(clientCookie)))
+		rdata = append(rdata, codeBuf...)
+		rdata = append(rdata, lenBuf...)
+		rdata = append(rdata, clientCookie...)
+	}
+
+	rdlenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(rdlenBuf, uint16(len(rdata)))
+	packet = append(packet, rdlenBuf...)
+	packet = append(packet, rdata...)
+
+	return packet
+}
+
+func TestParser_ParsesEDNS(t *testing.T) {
+	clientCookie := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	packet := buildTestQueryWithOPT("example.com", 4096, true, clientCookie)
+
+	p := NewParser()
+	msg, err := p.Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if msg.EDNS == nil {
+		t.Fatal("Parse() did not populate EDNS")
+	}
+	if msg.EDNS.UDPPayloadSize != 4096 {
+		t.Errorf("UDPPayloadSize = %d, want 4096", msg.EDNS.UDPPayloadSize)
+	}
+	if !msg.EDNS.DO {
+		t.Errorf("DO = false, want true")
+	}
+	if got := msg.EDNS.Cookie(); len(got) != 8 {
+		t.Fatalf("Cookie() = %v, want 8 bytes", got)
+	}
+}
+
+func TestBuildAResponse_EchoesCookieAndOPT(t *testing.T) {
+	clientCookie := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	packet := buildTestQueryWithOPT("example.com", 4096, false, clientCookie)
+
+	p := NewParser()
+	query, err := p.Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	resp := BuildAResponse(query, net.ParseIP("1.2.3.4"), 300)
+	respMsg, err := p.Parse(resp)
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+
+	if respMsg.EDNS == nil {
+		t.Fatal("response has no OPT record echoed back")
+	}
+
+	cookie := respMsg.EDNS.Cookie()
+	if len(cookie) != 16 {
+		t.Fatalf("response cookie length = %d, want 16 (client+server)", len(cookie))
+	}
+	for i, b := range clientCookie {
+		if cookie[i] != b {
+			t.Errorf("response did not echo client cookie byte %d: got %x want %x", i, cookie[i], b)
+		}
+	}
+}
+
+func TestNewECSOption_RoundTripsThroughECS(t *testing.T) {
+	opt := NewECSOption(24, 0, net.ParseIP("203.0.113.1"))
+	edns := &EDNS{Options: []EDNSOption{opt}}
+
+	ecs := edns.ECS()
+	if ecs == nil {
+		t.Fatal("ECS() = nil")
+	}
+	if ecs.SourcePrefixLen != 24 {
+		t.Errorf("SourcePrefixLen = %d, want 24", ecs.SourcePrefixLen)
+	}
+	if !ecs.Address.Equal(net.ParseIP("203.0.113.0")) {
+		t.Errorf("Address = %v, want 203.0.113.0 (truncated to /24)", ecs.Address)
+	}
+}
+
+func TestNewCookieOption(t *testing.T) {
+	client := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	edns := &EDNS{Options: []EDNSOption{NewCookieOption(client, nil)}}
+
+	if got := edns.Cookie(); len(got) != 8 {
+		t.Fatalf("Cookie() = %v, want 8 bytes", got)
+	}
+}
+
+func TestNewPaddingOption(t *testing.T) {
+	edns := &EDNS{Options: []EDNSOption{NewPaddingOption(16)}}
+
+	if got := edns.Padding(); len(got) != 16 {
+		t.Fatalf("Padding() = %v, want 16 zero bytes", got)
+	}
+}
+
+func TestBuildAResponse_NoTCWithinBudget(t *testing.T) {
+	// 客户端声明的 payload 大小最小也会被夹到 512, 一条普通 A 响应远小于这个值,
+	// 这里用最小的合法值验证预算内的响应不会被截断、也不会设置 TC 位。
+	packet := buildTestQueryWithOPT("example.com", 512, false, nil)
+
+	p := NewParser()
+	query, err := p.Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	resp := BuildAResponse(query, net.ParseIP("1.2.3.4"), 300)
+	if resp == nil {
+		t.Fatal("BuildAResponse() returned nil")
+	}
+
+	// 正常大小的响应不应当被截断
+	flags := binary.BigEndian.Uint16(resp[2:4])
+	if flags&0x0200 != 0 {
+		t.Errorf("TC bit set unexpectedly for a response well within budget")
+	}
+}
@@ -0,0 +1,120 @@
+package dns
+
+// Header.Flags 各比特位的含义 (RFC 1035 §4.1.1):
+//
+//	 0     1   2 3 4 5   6     7     8    9   10  11 12 13 14 15
+//	+--+-------+-----+--+--+--+----+----+---+---------+---------+
+//	|QR| OPCODE |  AA|TC|RD|RA| Z  |  AD| CD|  RCODE  (低 4 位)  |
+//	+--+-------+-----+--+--+--+----+----+---+---------+---------+
+//
+// 下面这组方法把这些比特位封装成具名的访问器/设置器, 调用方不再需要记忆具体
+// 的掩码和偏移量。
+
+const (
+	flagQR      uint16 = 1 << 15
+	flagAA      uint16 = 1 << 10
+	flagTC      uint16 = 1 << 9
+	flagRD      uint16 = 1 << 8
+	flagRA      uint16 = 1 << 7
+	flagZMask   uint16 = 0x0070
+	flagZShift         = 4
+	opcodeMask  uint16 = 0x7800
+	opcodeShift        = 11
+	rcodeMask   uint16 = 0x000F
+)
+
+// Opcode 常量 (RFC 1035 §4.1.1 / RFC 2136)
+const (
+	OpcodeQuery  uint8 = 0
+	OpcodeIQuery uint8 = 1
+	OpcodeStatus uint8 = 2
+	OpcodeNotify uint8 = 4
+	OpcodeUpdate uint8 = 5
+)
+
+// RCODE 常量 (RFC 1035 §4.1.1 / RFC 2136 §2.3)
+const (
+	NoError  uint8 = 0
+	FormErr  uint8 = 1
+	ServFail uint8 = 2
+	NXDomain uint8 = 3
+	NotImp   uint8 = 4
+	Refused  uint8 = 5
+	YXDomain uint8 = 6
+	YXRRSet  uint8 = 7
+	NXRRSet  uint8 = 8
+	NotAuth  uint8 = 9
+	NotZone  uint8 = 10
+)
+
+// QR 判断 Flags 的 QR 位: false 为查询, true 为响应
+func (h Header) QR() bool { return h.Flags&flagQR != 0 }
+
+// SetQR 设置 Flags 的 QR 位
+func (h *Header) SetQR(v bool) { h.setFlag(flagQR, v) }
+
+// Opcode 返回 Flags 中的 OPCODE (4 位)
+func (h Header) Opcode() uint8 { return uint8((h.Flags & opcodeMask) >> opcodeShift) }
+
+// SetOpcode 设置 Flags 中的 OPCODE (4 位)
+func (h *Header) SetOpcode(op uint8) {
+	h.Flags = (h.Flags &^ opcodeMask) | (uint16(op)<<opcodeShift)&opcodeMask
+}
+
+// AA 判断 Flags 的 Authoritative Answer 位
+func (h Header) AA() bool { return h.Flags&flagAA != 0 }
+
+// SetAA 设置 Flags 的 Authoritative Answer 位
+func (h *Header) SetAA(v bool) { h.setFlag(flagAA, v) }
+
+// TC 判断 Flags 的 Truncated 位
+func (h Header) TC() bool { return h.Flags&flagTC != 0 }
+
+// SetTC 设置 Flags 的 Truncated 位
+func (h *Header) SetTC(v bool) { h.setFlag(flagTC, v) }
+
+// RD 判断 Flags 的 Recursion Desired 位
+func (h Header) RD() bool { return h.Flags&flagRD != 0 }
+
+// SetRD 设置 Flags 的 Recursion Desired 位
+func (h *Header) SetRD(v bool) { h.setFlag(flagRD, v) }
+
+// RA 判断 Flags 的 Recursion Available 位
+func (h Header) RA() bool { return h.Flags&flagRA != 0 }
+
+// SetRA 设置 Flags 的 Recursion Available 位
+func (h *Header) SetRA(v bool) { h.setFlag(flagRA, v) }
+
+// Z 返回保留位 (3 位, RFC 1035 中恒为 0, 现实中常被 AD/CD 占用其中 2 位)
+func (h Header) Z() uint8 { return uint8((h.Flags & flagZMask) >> flagZShift) }
+
+// RCODE 返回 Flags 低 4 位的响应码
+func (h Header) RCODE() uint8 { return uint8(h.Flags & rcodeMask) }
+
+// SetRCODE 设置 Flags 低 4 位的响应码
+func (h *Header) SetRCODE(rcode uint8) {
+	h.Flags = (h.Flags &^ rcodeMask) | uint16(rcode)&rcodeMask
+}
+
+func (h *Header) setFlag(bit uint16, v bool) {
+	if v {
+		h.Flags |= bit
+	} else {
+		h.Flags &^= bit
+	}
+}
+
+// NewResponse 基于 m (通常是刚解析出的查询) 构造一条预填好头部的应答: QR=1,
+// RD 原样回显, RA=1, RCODE=rcode, 沿用同一个事务 ID 和 Questions, 供 XDP 快速
+// 路径对命中黑名单的域名直接拼 NXDOMAIN/REFUSED 而不必手搓比特位
+func (m *Message) NewResponse(rcode uint8) *Message {
+	resp := &Message{
+		Header:    Header{ID: m.Header.ID},
+		Questions: m.Questions,
+	}
+	resp.Header.SetQR(true)
+	resp.Header.SetRD(m.Header.RD())
+	resp.Header.SetRA(true)
+	resp.Header.SetRCODE(rcode)
+	return resp
+}
@@ -0,0 +1,54 @@
+package dns
+
+import "testing"
+
+func TestHeader_FlagAccessors(t *testing.T) {
+	var h Header
+	h.SetQR(true)
+	h.SetOpcode(OpcodeUpdate)
+	h.SetAA(true)
+	h.SetTC(true)
+	h.SetRD(true)
+	h.SetRA(true)
+	h.SetRCODE(NXDomain)
+
+	if !h.QR() || !h.AA() || !h.TC() || !h.RD() || !h.RA() {
+		t.Fatalf("flags = %#04x, want QR/AA/TC/RD/RA all set", h.Flags)
+	}
+	if h.Opcode() != OpcodeUpdate {
+		t.Errorf("Opcode() = %d, want %d", h.Opcode(), OpcodeUpdate)
+	}
+	if h.RCODE() != NXDomain {
+		t.Errorf("RCODE() = %d, want %d", h.RCODE(), NXDomain)
+	}
+
+	h.SetQR(false)
+	h.SetRCODE(NoError)
+	if h.QR() {
+		t.Error("SetQR(false) should clear QR")
+	}
+	if h.RCODE() != NoError {
+		t.Errorf("RCODE() = %d, want NoError after SetRCODE(NoError)", h.RCODE())
+	}
+}
+
+func TestMessage_NewResponse(t *testing.T) {
+	query := &Message{
+		Header:    Header{ID: 0x4242, Flags: 0x0100}, // RD=1
+		Questions: []Question{{Name: "blocked.example.com", QType: TypeA, QClass: ClassIN}},
+	}
+
+	resp := query.NewResponse(NXDomain)
+	if resp.Header.ID != 0x4242 {
+		t.Errorf("ID = %#x, want 0x4242", resp.Header.ID)
+	}
+	if !resp.Header.QR() || !resp.Header.RA() || !resp.Header.RD() {
+		t.Fatalf("flags = %#04x, want QR/RA/RD all set", resp.Header.Flags)
+	}
+	if resp.Header.RCODE() != NXDomain {
+		t.Errorf("RCODE() = %d, want NXDomain", resp.Header.RCODE())
+	}
+	if len(resp.Questions) != 1 || resp.Questions[0].Name != "blocked.example.com" {
+		t.Fatalf("Questions = %+v", resp.Questions)
+	}
+}
@@ -15,24 +15,37 @@
 package hybrid
 
 import (
+	"context"
 	"encoding/binary"
+	"log"
 	"sync"
 
+	"xdp-dns/pkg/dns"
 	"xdp-dns/pkg/dns/cppbridge"
+	"xdp-dns/pkg/dnscache"
 	"xdp-dns/pkg/filter"
+	"xdp-dns/pkg/forwarder"
 )
 
 // Processor 混合架构 DNS 处理器
 type Processor struct {
 	engine *filter.Engine
 	mu     sync.RWMutex
+	cache  *dnscache.Cache // 可选的响应缓存, 见 SetCache; nil 时不启用
 
 	// 统计
-	processed   uint64
-	allowed     uint64
-	blocked     uint64
-	redirected  uint64
-	parseErrors uint64
+	processed     uint64
+	allowed       uint64
+	blocked       uint64
+	redirected    uint64
+	parseErrors   uint64
+	forwarded     uint64
+	forwardErrors uint64
+	ednsFallback  uint64
+	answered      uint64
+	answerErrors  uint64
+	cacheHits     uint64
+	cacheMisses   uint64
 }
 
 // NewProcessor 创建新的混合处理器
@@ -52,6 +65,22 @@ func (p *Processor) Close() {
 	cppbridge.Cleanup()
 }
 
+// SetCache 给处理器挂载一个 pkg/dnscache 响应缓存: Process/processWithEDNSFallback
+// 在走 Engine.CheckDomain 之前先查这个缓存, 命中时直接返回缓存的响应字节, 跳过
+// Trie 匹配与响应构建; 传 nil 可在运行时关闭缓存。与 filter.Engine.SetSink 挂载
+// 可选 Sink 的方式一致, 不改动 NewProcessor 的构造签名。
+func (p *Processor) SetCache(c *dnscache.Cache) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache = c
+}
+
+func (p *Processor) cacheSnapshot() *dnscache.Cache {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cache
+}
+
 // ProcessResult 处理结果
 type ProcessResult struct {
 	Action   filter.Action
@@ -63,6 +92,17 @@ type ProcessResult struct {
 // Process 处理 DNS 数据包
 // 返回处理结果和响应数据(如果需要)
 func (p *Processor) Process(packet []byte) (*ProcessResult, error) {
+	// cppbridge.ParseResult (由外部 C++ 库的 XDPDNSParseResult ABI 决定, 本仓库不包含
+	// 该库的源码) 不携带 EDNS(0) 信息, C++ 响应构建器也不会生成 OPT 伪记录。为了不在
+	// 快路径上悄悄丢弃客户端协商的 UDP 负载大小/Cookie/ECS 回显, 只要查询带有附加部分
+	// (很可能携带 OPT), 就整体退回到慢路径: 用 Go 的 dns.Parser/Engine.CheckDomain/
+	// dns.Build*Response 处理, 牺牲这一个包的性能换取 EDNS 语义正确, 而不是改动缺失的
+	// C++ ABI。等 cppbridge 的 ParseResult 补上 EDNS 字段后可以去掉这个分支。
+	if len(packet) >= 12 && binary.BigEndian.Uint16(packet[10:12]) > 0 {
+		p.ednsFallback++
+		return p.processWithEDNSFallback(packet)
+	}
+
 	// Step 1: C++ 高性能解析 (12ns)
 	parsed, err := cppbridge.Parse(packet)
 	if err != nil {
@@ -70,8 +110,21 @@ func (p *Processor) Process(packet []byte) (*ProcessResult, error) {
 		return nil, err
 	}
 
+	cache := p.cacheSnapshot()
+	key := dnscache.NewKey(parsed.Domain, parsed.QType, parsed.QClass)
+	if cache != nil {
+		if resp, hit := cache.Get(key, binary.BigEndian.Uint16(packet[0:2]), nil); hit {
+			p.cacheHits++
+			return &ProcessResult{Action: filter.ActionAllow, Response: resp, Domain: parsed.Domain}, nil
+		}
+		p.cacheMisses++
+	}
+
 	// Step 2: Go Trie 匹配 (187ns) - Go 比 C++ 快 2-3x
-	result, err := p.engine.CheckDomain(parsed.Domain, parsed.QType)
+	// 走到这里的查询没有附加部分, 不带 OPT/ECS, 因此没有 CheckContext 可传,
+	// 带 client_subnets 的规则在这条快路径上不匹配; 携带 ECS 的查询已在函数
+	// 开头回退到 processWithEDNSFallback, 在那里按 ECS 地址匹配
+	result, err := p.engine.CheckDomain(parsed.Domain, parsed.QType, nil)
 	if err != nil {
 		return &ProcessResult{
 			Action: filter.ActionAllow,
@@ -99,6 +152,9 @@ func (p *Processor) Process(packet []byte) (*ProcessResult, error) {
 		if err != nil {
 			return nil, err
 		}
+		if cache != nil {
+			cache.PutNegative(key, pr.Response, 0, dnscache.PinFor(result.Action))
+		}
 
 	case filter.ActionRedirect:
 		p.redirected++
@@ -117,11 +173,248 @@ func (p *Processor) Process(packet []byte) (*ProcessResult, error) {
 			if err != nil {
 				return nil, err
 			}
+			if cache != nil && pr.Response != nil {
+				cache.Put(key, pr.Response, result.TTL, dnscache.PinFor(result.Action))
+			}
 		}
 
 	case filter.ActionLog:
 		p.allowed++
 		// 记录但放行
+
+	case filter.ActionForward:
+		// 转发给上游解析器并发竞速, 见 pkg/forwarder。原始报文不经 C++ 层改动,
+		// 直接把上游原始应答字节交给 AF_XDP TX 环发送。
+		if result.Rule == nil || len(result.Rule.Forwarders) == 0 {
+			p.forwardErrors++
+			break
+		}
+		ups := make([]forwarder.Upstream, len(result.Rule.Forwarders))
+		for i, u := range result.Rule.Forwarders {
+			ups[i] = forwarder.Upstream{Addr: u.Addr, StartDelay: u.StartDelay}
+		}
+		fwd := forwarder.New(ups, forwarder.DefaultTimeout)
+		resp, ferr := fwd.Forward(context.Background(), packet)
+		if ferr != nil {
+			log.Printf("hybrid: forward to upstream failed: %v", ferr)
+			p.forwardErrors++
+			break
+		}
+		p.forwarded++
+		pr.Response = resp
+		if cache != nil {
+			if ttl, negative, ok := cacheTTLForForwarded(resp); ok {
+				if negative {
+					cache.PutNegative(key, resp, ttl, false)
+				} else {
+					cache.Put(key, resp, ttl, false)
+				}
+			}
+		}
+
+	case filter.ActionAnswer:
+		// 从 Rule.LocalAnswer 这个常驻内存的小型区域直接应答 (类似 Tailscale 的
+		// Config.Hosts)。C++ 响应构建器没有 REFUSED/NODATA 构造函数, 因此只有请求类
+		// 与本地记录直接匹配的常见情形才走 C++ 快路径, 类不匹配或查询类型没有对应记录
+		// 时整体退回 Go 路径以得到正确的 REFUSED/NODATA 响应, 与上面的 EDNS 回退同理。
+		if result.Rule == nil || result.Rule.LocalAnswer == nil {
+			p.answerErrors++
+			break
+		}
+		if parsed.QClass != dns.ClassIN {
+			return p.processWithEDNSFallback(packet)
+		}
+		local := result.Rule.LocalAnswer
+		switch {
+		case parsed.QType == dns.TypeAAAA && len(local.AAAA) > 0:
+			var ipv6 [16]byte
+			copy(ipv6[:], local.NextAAAA().To16())
+			pr.Response, err = cppbridge.BuildAAAAResponse(packet, ipv6, local.TTL)
+		case parsed.QType == dns.TypeA && len(local.A) > 0:
+			ipv4 := binary.BigEndian.Uint32(local.NextA().To4())
+			pr.Response, err = cppbridge.BuildAResponse(packet, ipv4, local.TTL)
+		default:
+			return p.processWithEDNSFallback(packet)
+		}
+		if err != nil {
+			p.answerErrors++
+			return nil, err
+		}
+		p.answered++
+		if cache != nil {
+			cache.Put(key, pr.Response, local.TTL, dnscache.PinFor(result.Action))
+		}
+	}
+
+	// cppbridge 构建的响应没有 EDNS 协商, 也不实现按负载大小截断/设置 TC 位
+	// (RFC 1035 §2.3.4, RFC 7766): 这条分支只会在没有 OPT 记录的查询上走到
+	// (带 OPT 的查询在函数开头就已经整体回退到 processWithEDNSFallback), 因此
+	// 上限固定是传统的 512 字节。目前 cppbridge 每次只构建单条 A/AAAA/NXDOMAIN
+	// 应答, 正常情况下不会超限; 这里是防御性兜底, 真正需要截断时退回 Go 慢路径,
+	// 复用 dns.Build*Response 的 finish() 里已经实现的截断与 TC 位逻辑, 而不是
+	// 在这里手工修补 C++ 构建器返回的裸字节。
+	if len(pr.Response) > maxUDPResponseSize {
+		return p.processWithEDNSFallback(packet)
+	}
+
+	return pr, nil
+}
+
+// maxUDPResponseSize 是没有 EDNS 协商时传统 UDP 响应的上限 (RFC 1035 §2.3.4),
+// 与 pkg/dns 内部的 noEDNSMaxSize 取值一致
+const maxUDPResponseSize = 512
+
+// cacheTTLForForwarded 从 ActionForward 转发回来的上游原始应答里提取写入 pkg/dnscache
+// 所需的 TTL: 肯定应答取所有 Answer 记录里最小的 TTL (标准权威/递归实现的通常做法),
+// 否定应答 (NXDOMAIN, 或 NOERROR 但没有 Answer 即 NODATA) 按 RFC 2308 取 Authority
+// 部分 SOA 记录的 MINIMUM 字段, 与 dnscache.Cache.PutNegative 的约定一致。response 解析
+// 失败或提取不出可用的 TTL 时 ok 为 false, 调用方此时应跳过缓存而不是写入一个臆造的值。
+func cacheTTLForForwarded(response []byte) (ttl uint32, negative bool, ok bool) {
+	msg, err := dns.NewParser().Parse(response)
+	if err != nil || len(msg.Questions) == 0 {
+		return 0, false, false
+	}
+
+	if msg.GetRCode() == dns.RCodeNXDomain || (msg.GetRCode() == dns.RCodeNoError && len(msg.Answers) == 0) {
+		for i := range msg.Authority {
+			if msg.Authority[i].Type != dns.TypeSOA {
+				continue
+			}
+			rdata, derr := dns.DecodeRData(&msg.Authority[i], response)
+			if derr != nil {
+				continue
+			}
+			if soa, ok2 := rdata.(dns.SOARecord); ok2 {
+				return soa.Minimum, true, true
+			}
+		}
+		return 0, true, false
+	}
+
+	for _, rr := range msg.Answers {
+		if ttl == 0 || rr.TTL < ttl {
+			ttl = rr.TTL
+		}
+	}
+	return ttl, false, ttl > 0
+}
+
+// processWithEDNSFallback 是 Process 在查询带有附加部分 (很可能是 EDNS OPT 记录) 时
+// 走的慢路径, 完全使用 Go 实现的 dns.Parser/Engine.CheckDomain/dns.Build*Response,
+// 因此能完整保留 OPT 负载协商、Cookie 回显、ECS 匹配与按负载截断 (TC 位)。
+func (p *Processor) processWithEDNSFallback(packet []byte) (*ProcessResult, error) {
+	parser := dns.NewParser()
+	msg, err := parser.Parse(packet)
+	if err != nil {
+		p.parseErrors++
+		return nil, err
+	}
+	if len(msg.Questions) == 0 {
+		p.parseErrors++
+		return nil, cppbridge.ErrNotDNSQuery
+	}
+
+	domain := msg.GetQueryDomain()
+	qclass := msg.Questions[0].QClass
+
+	cache := p.cacheSnapshot()
+	key := dnscache.NewKey(domain, msg.GetQueryType(), qclass)
+	if cache != nil {
+		if resp, hit := cache.Get(key, msg.Header.ID, msg.EDNS.Cookie()); hit {
+			p.cacheHits++
+			return &ProcessResult{Action: filter.ActionAllow, Response: resp, Domain: domain}, nil
+		}
+		p.cacheMisses++
+	}
+
+	ctx := filter.NewCheckContext("", msg.EDNS)
+	result, err := p.engine.CheckDomain(domain, msg.GetQueryType(), ctx)
+	if err != nil {
+		return &ProcessResult{Action: filter.ActionAllow, Domain: domain}, nil
+	}
+
+	p.processed++
+	pr := &ProcessResult{Action: result.Action, Domain: domain, RuleID: result.RuleID}
+
+	switch result.Action {
+	case filter.ActionAllow:
+		p.allowed++
+
+	case filter.ActionBlock:
+		p.blocked++
+		pr.Response = dns.BuildNXDomainResponse(msg)
+		if cache != nil {
+			cache.PutNegative(key, pr.Response, 0, dnscache.PinFor(result.Action))
+		}
+
+	case filter.ActionRedirect:
+		p.redirected++
+		if result.RedirectIP != nil {
+			if msg.GetQueryType() == dns.TypeAAAA {
+				pr.Response = dns.BuildAAAAResponse(msg, result.RedirectIP, result.TTL)
+			} else {
+				pr.Response = dns.BuildAResponse(msg, result.RedirectIP, result.TTL)
+			}
+			if cache != nil {
+				cache.Put(key, pr.Response, result.TTL, dnscache.PinFor(result.Action))
+			}
+		}
+
+	case filter.ActionLog:
+		p.allowed++
+
+	case filter.ActionForward:
+		if result.Rule == nil || len(result.Rule.Forwarders) == 0 {
+			p.forwardErrors++
+			break
+		}
+		ups := make([]forwarder.Upstream, len(result.Rule.Forwarders))
+		for i, u := range result.Rule.Forwarders {
+			ups[i] = forwarder.Upstream{Addr: u.Addr, StartDelay: u.StartDelay}
+		}
+		fwd := forwarder.New(ups, forwarder.DefaultTimeout)
+		resp, ferr := fwd.Forward(context.Background(), packet)
+		if ferr != nil {
+			log.Printf("hybrid: forward to upstream failed: %v", ferr)
+			p.forwardErrors++
+			break
+		}
+		p.forwarded++
+		pr.Response = resp
+		if cache != nil {
+			if ttl, negative, ok := cacheTTLForForwarded(resp); ok {
+				if negative {
+					cache.PutNegative(key, resp, ttl, false)
+				} else {
+					cache.Put(key, resp, ttl, false)
+				}
+			}
+		}
+
+	case filter.ActionAnswer:
+		if result.Rule == nil || result.Rule.LocalAnswer == nil {
+			p.answerErrors++
+			break
+		}
+		if len(msg.Questions) > 0 && msg.Questions[0].QClass != dns.ClassIN {
+			pr.Response = dns.BuildRefusedResponse(msg)
+			break
+		}
+		local := result.Rule.LocalAnswer
+		switch {
+		case msg.GetQueryType() == dns.TypeAAAA && len(local.AAAA) > 0:
+			pr.Response = dns.BuildAAAAResponse(msg, local.NextAAAA(), local.TTL)
+		case msg.GetQueryType() == dns.TypeA && len(local.A) > 0:
+			pr.Response = dns.BuildAResponse(msg, local.NextA(), local.TTL)
+		case msg.GetQueryType() == dns.TypePTR && local.PTR != "":
+			pr.Response = dns.BuildPTRResponse(msg, local.PTR, local.TTL)
+		default:
+			pr.Response = dns.BuildRewriteResponse(msg, dns.RCodeNoError, true, nil)
+		}
+		p.answered++
+		if cache != nil {
+			cache.Put(key, pr.Response, local.TTL, dnscache.PinFor(result.Action))
+		}
 	}
 
 	return pr, nil
@@ -132,12 +425,19 @@ func (p *Processor) Stats() ProcessorStats {
 	cppStats := cppbridge.GetStats()
 
 	return ProcessorStats{
-		Processed:       p.processed,
-		Allowed:         p.allowed,
-		Blocked:         p.blocked,
-		Redirected:      p.redirected,
-		ParseErrors:     p.parseErrors,
-		CPPParseCount:   cppStats.PacketsParsed,
+		Processed:        p.processed,
+		Allowed:          p.allowed,
+		Blocked:          p.blocked,
+		Redirected:       p.redirected,
+		ParseErrors:      p.parseErrors,
+		Forwarded:        p.forwarded,
+		ForwardErrors:    p.forwardErrors,
+		EDNSFallback:     p.ednsFallback,
+		Answered:         p.answered,
+		AnswerErrors:     p.answerErrors,
+		CacheHits:        p.cacheHits,
+		CacheMisses:      p.cacheMisses,
+		CPPParseCount:    cppStats.PacketsParsed,
 		CPPResponseBuilt: cppStats.ResponseBuilt,
 	}
 }
@@ -149,6 +449,13 @@ type ProcessorStats struct {
 	Blocked          uint64
 	Redirected       uint64
 	ParseErrors      uint64
+	Forwarded        uint64
+	ForwardErrors    uint64
+	EDNSFallback     uint64
+	Answered         uint64
+	AnswerErrors     uint64
+	CacheHits        uint64 // 命中 pkg/dnscache 缓存、跳过 Engine.CheckDomain 的查询数
+	CacheMisses      uint64 // 挂载了缓存但未命中, 仍然走了正常规则匹配的查询数
 	CPPParseCount    uint64
 	CPPResponseBuilt uint64
 }
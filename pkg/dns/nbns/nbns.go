@@ -0,0 +1,279 @@
+// Package nbns 解析 NetBIOS 名称服务 (NBNS, RFC 1001/1002, UDP/137) 报文
+//
+// NBNS 报文沿用了和 DNS 一模一样的 12 字节头部、问题/回答区段结构甚至压缩指针,
+// 唯一的区别是名字的编码: NBNS 把 16 字节定长的 NetBIOS 名字先做"一级编码"
+// (First Level Encoding, RFC 1001 §14.1), 把每个字节拆成高/低两个半字节各加上
+// 'A', 变成一个 32 字节、只含大写字母的标签, 再按 DNS 标签规则 (长度前缀 0x20)
+// 写入报文。跑 XDP DNS 过滤器的宿主机往往在同一张网卡/同一个 socket 上也能看到
+// 局域网里的 NBNS 广播流量, 本包让用户态组件可以用和 DNS 解析同一套抽象
+// (Header/Question/ResourceRecord) 来识别并按需丢弃这部分流量。
+package nbns
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"xdp-dns/pkg/dns"
+)
+
+var (
+	// ErrTooShort 报文不足 12 字节头部
+	ErrTooShort = errors.New("nbns: message too short")
+	// ErrTruncated 报文在某个字段中途结束
+	ErrTruncated = errors.New("nbns: message truncated")
+	// ErrInvalidName NBNS 一级编码名字不是 32 字节或包含非法字符
+	ErrInvalidName = errors.New("nbns: invalid first-level encoded name")
+)
+
+// TypeNB 是 NBNS 的 NB (NetBIOS 通用名字) 记录类型 (RFC 1002 §4.2)
+const TypeNB uint16 = 0x0020
+
+// TypeNBSTAT 是 NBNS 的 NBSTAT (节点状态查询) 记录类型 (RFC 1002 §4.4)
+const TypeNBSTAT uint16 = 0x0021
+
+// 常见的 NetBIOS 名字后缀 (第 16 字节, 服务类型), 见
+// https://support.microsoft.com/en-us/topic/ NetBIOS 后缀清单
+const (
+	SuffixWorkstation       uint8 = 0x00
+	SuffixMessenger         uint8 = 0x03
+	SuffixFileServer        uint8 = 0x20
+	SuffixDomainMasterBrwsr uint8 = 0x1B
+)
+
+// Question NBNS 问题部分: 解码后的 NetBIOS 名字 + 类型 + 类
+type Question struct {
+	Name   string // 去除尾部空格后的 15 字节名字部分
+	Suffix uint8  // 第 16 字节, 服务类型
+	Type   uint16
+	Class  uint16
+}
+
+// ResourceRecord NBNS 资源记录: 解码后的 NetBIOS 名字 + 类型/类/TTL/RDATA
+type ResourceRecord struct {
+	Name   string
+	Suffix uint8
+	Type   uint16
+	Class  uint16
+	TTL    uint32
+	RData  []byte
+}
+
+// NBNSMessage 解析后的 NBNS 报文
+type NBNSMessage struct {
+	Header    dns.Header
+	Questions []Question
+	Answers   []ResourceRecord
+}
+
+// NodeStatusEntry NBSTAT 回答里 NUM_NAMES 张表中的一条记录 (RFC 1002 §4.4.1)
+type NodeStatusEntry struct {
+	Name   string
+	Suffix uint8
+	Flags  uint16
+}
+
+// NodeStatus 解码后的 NBSTAT 回答
+type NodeStatus struct {
+	Names []NodeStatusEntry
+	MAC   [6]byte
+}
+
+// Parse 解析一段 NBNS 报文, 复用 DNS 报文的头部/压缩指针结构, 但按 NBNS 的
+// 一级编码规则解码名字
+func Parse(data []byte) (*NBNSMessage, error) {
+	if len(data) < 12 {
+		return nil, ErrTooShort
+	}
+
+	msg := &NBNSMessage{
+		Header: dns.Header{
+			ID:      binary.BigEndian.Uint16(data[0:2]),
+			Flags:   binary.BigEndian.Uint16(data[2:4]),
+			QDCount: binary.BigEndian.Uint16(data[4:6]),
+			ANCount: binary.BigEndian.Uint16(data[6:8]),
+			NSCount: binary.BigEndian.Uint16(data[8:10]),
+			ARCount: binary.BigEndian.Uint16(data[10:12]),
+		},
+	}
+
+	offset := 12
+	for i := uint16(0); i < msg.Header.QDCount; i++ {
+		q, newOffset, err := parseQuestion(data, offset)
+		if err != nil {
+			break // 与 dns.Parser 的宽松模式一致: 允许部分解析
+		}
+		msg.Questions = append(msg.Questions, q)
+		offset = newOffset
+	}
+
+	for i := uint16(0); i < msg.Header.ANCount && offset < len(data); i++ {
+		rr, newOffset, err := parseResourceRecord(data, offset)
+		if err != nil {
+			break
+		}
+		msg.Answers = append(msg.Answers, rr)
+		offset = newOffset
+	}
+
+	return msg, nil
+}
+
+func parseQuestion(data []byte, offset int) (Question, int, error) {
+	name, suffix, newOffset, err := parseName(data, offset)
+	if err != nil {
+		return Question{}, 0, err
+	}
+	if newOffset+4 > len(data) {
+		return Question{}, 0, ErrTruncated
+	}
+	return Question{
+		Name:   name,
+		Suffix: suffix,
+		Type:   binary.BigEndian.Uint16(data[newOffset : newOffset+2]),
+		Class:  binary.BigEndian.Uint16(data[newOffset+2 : newOffset+4]),
+	}, newOffset + 4, nil
+}
+
+func parseResourceRecord(data []byte, offset int) (ResourceRecord, int, error) {
+	name, suffix, newOffset, err := parseName(data, offset)
+	if err != nil {
+		return ResourceRecord{}, 0, err
+	}
+	if newOffset+10 > len(data) {
+		return ResourceRecord{}, 0, ErrTruncated
+	}
+
+	rr := ResourceRecord{
+		Name:   name,
+		Suffix: suffix,
+		Type:   binary.BigEndian.Uint16(data[newOffset : newOffset+2]),
+		Class:  binary.BigEndian.Uint16(data[newOffset+2 : newOffset+4]),
+		TTL:    binary.BigEndian.Uint32(data[newOffset+4 : newOffset+8]),
+	}
+	rdlen := int(binary.BigEndian.Uint16(data[newOffset+8 : newOffset+10]))
+	newOffset += 10
+	rdataEnd := newOffset + rdlen
+	if rdataEnd > len(data) {
+		return ResourceRecord{}, 0, ErrTruncated
+	}
+	rr.RData = data[newOffset:rdataEnd]
+	return rr, rdataEnd, nil
+}
+
+// parseName 解析报文里从 offset 开始的一个 NBNS 名字: 先按 DNS 的压缩指针/长度
+// 前缀标签规则读出第一个标签的原始字节 (通常 32 字节), 再做一级解码还原成
+// 16 字节的 NetBIOS 名字, 返回去除尾部空格的前 15 字节作为 Name, 第 16 字节
+// 作为 Suffix (服务类型)
+func parseName(data []byte, offset int) (string, uint8, int, error) {
+	label, newOffset, err := readFirstLabel(data, offset)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	decoded, err := decodeFirstLevel(label)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	name := strings.TrimRight(string(decoded[:15]), " ")
+	suffix := decoded[15]
+	return name, suffix, newOffset, nil
+}
+
+// readFirstLabel 跳过可能的压缩指针跳转, 读出第一个长度前缀标签的原始字节,
+// 返回紧跟在这个名字 (含结尾 0x00) 之后的偏移
+func readFirstLabel(data []byte, offset int) ([]byte, int, error) {
+	originalOffset := -1
+
+	for {
+		if offset >= len(data) {
+			return nil, 0, ErrTruncated
+		}
+		length := int(data[offset])
+
+		if length&0xC0 == 0xC0 {
+			if offset+1 >= len(data) {
+				return nil, 0, ErrTruncated
+			}
+			if originalOffset < 0 {
+				originalOffset = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(data[offset:offset+2]) & 0x3FFF)
+			continue
+		}
+
+		if offset+1+length > len(data) {
+			return nil, 0, ErrTruncated
+		}
+		label := data[offset+1 : offset+1+length]
+
+		end := offset + 1 + length
+		if originalOffset < 0 {
+			// 跳过名字里剩余的标签 (通常只有结尾的 0x00), 直到遇到根标签
+			for end < len(data) && data[end] != 0 {
+				l := int(data[end])
+				if l&0xC0 == 0xC0 {
+					end += 2
+					break
+				}
+				end += 1 + l
+			}
+			if end < len(data) && data[end] == 0 {
+				end++
+			}
+			originalOffset = end
+		}
+
+		return label, originalOffset, nil
+	}
+}
+
+// decodeFirstLevel 把一个 32 字节的一级编码标签还原成 16 字节的 NetBIOS 名字
+// (RFC 1001 §14.1): 每两个字节一组, 各减去 'A' 得到一个半字节, 拼成
+// high<<4 | low
+func decodeFirstLevel(label []byte) ([16]byte, error) {
+	var out [16]byte
+	if len(label) != 32 {
+		return out, ErrInvalidName
+	}
+	for i := 0; i < 16; i++ {
+		hi := label[i*2]
+		lo := label[i*2+1]
+		if hi < 'A' || hi > 'P' || lo < 'A' || lo > 'P' {
+			return out, ErrInvalidName
+		}
+		out[i] = (hi-'A')<<4 | (lo - 'A')
+	}
+	return out, nil
+}
+
+// DecodeNodeStatus 把一条 NBSTAT (RFC 1002 §4.4) 回答的 RDATA 解码为节点状态:
+// NUM_NAMES(1) + NUM_NAMES 条 <NetBIOS 名字(15)+后缀(1)+FLAGS(2)> + STATISTICS
+// (本实现只取 STATISTICS 最前面的 6 字节 MAC 地址, 其余统计字段不解析)
+func DecodeNodeStatus(rdata []byte) (*NodeStatus, error) {
+	if len(rdata) < 1 {
+		return nil, ErrTruncated
+	}
+	numNames := int(rdata[0])
+	pos := 1
+
+	status := &NodeStatus{}
+	for i := 0; i < numNames; i++ {
+		if pos+18 > len(rdata) {
+			return nil, ErrTruncated
+		}
+		name := strings.TrimRight(string(rdata[pos:pos+15]), " ")
+		suffix := rdata[pos+15]
+		flags := binary.BigEndian.Uint16(rdata[pos+16 : pos+18])
+		status.Names = append(status.Names, NodeStatusEntry{Name: name, Suffix: suffix, Flags: flags})
+		pos += 18
+	}
+
+	if pos+6 > len(rdata) {
+		return nil, ErrTruncated
+	}
+	copy(status.MAC[:], rdata[pos:pos+6])
+
+	return status, nil
+}
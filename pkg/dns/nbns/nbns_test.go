@@ -0,0 +1,147 @@
+package nbns
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// encodeFirstLevel 是 decodeFirstLevel 的逆操作, 仅供测试构造报文使用
+func encodeFirstLevel(name string, suffix uint8) []byte {
+	var raw [16]byte
+	copy(raw[:15], name)
+	for i := len(name); i < 15; i++ {
+		raw[i] = ' '
+	}
+	raw[15] = suffix
+
+	encoded := make([]byte, 32)
+	for i, b := range raw {
+		encoded[i*2] = 'A' + (b >> 4)
+		encoded[i*2+1] = 'A' + (b & 0x0F)
+	}
+	return encoded
+}
+
+func buildNBNSQuery(name string, suffix uint8, qtype uint16) []byte {
+	packet := []byte{
+		0x12, 0x34, // ID
+		0x00, 0x10, // Flags (broadcast query)
+		0x00, 0x01, // QDCount = 1
+		0x00, 0x00, // ANCount = 0
+		0x00, 0x00, // NSCount = 0
+		0x00, 0x00, // ARCount = 0
+	}
+	packet = append(packet, 0x20) // 标签长度 32
+	packet = append(packet, encodeFirstLevel(name, suffix)...)
+	packet = append(packet, 0x00) // 根标签
+
+	typeBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(typeBuf, qtype)
+	packet = append(packet, typeBuf...)
+	packet = append(packet, 0x00, 0x01) // Class IN
+
+	return packet
+}
+
+func TestParse_Question(t *testing.T) {
+	packet := buildNBNSQuery("WORKGROUP", SuffixFileServer, TypeNB)
+
+	msg, err := Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if msg.Header.ID != 0x1234 {
+		t.Errorf("ID = %#x, want 0x1234", msg.Header.ID)
+	}
+	if len(msg.Questions) != 1 {
+		t.Fatalf("Questions = %+v, want 1", msg.Questions)
+	}
+	q := msg.Questions[0]
+	if q.Name != "WORKGROUP" {
+		t.Errorf("Name = %q, want WORKGROUP", q.Name)
+	}
+	if q.Suffix != SuffixFileServer {
+		t.Errorf("Suffix = %#x, want %#x", q.Suffix, SuffixFileServer)
+	}
+	if q.Type != TypeNB {
+		t.Errorf("Type = %#x, want TypeNB", q.Type)
+	}
+}
+
+func TestParse_AnswerWithRData(t *testing.T) {
+	packet := []byte{
+		0x99, 0x88, // ID
+		0x84, 0x00, // Flags (response, authoritative)
+		0x00, 0x00, // QDCount
+		0x00, 0x01, // ANCount = 1
+		0x00, 0x00,
+		0x00, 0x00,
+	}
+	packet = append(packet, 0x20)
+	packet = append(packet, encodeFirstLevel("HOST1", SuffixWorkstation)...)
+	packet = append(packet, 0x00)
+	packet = append(packet, 0x00, 0x20)             // Type NB
+	packet = append(packet, 0x00, 0x01)             // Class IN
+	packet = append(packet, 0x00, 0x00, 0x00, 0xA0) // TTL
+	packet = append(packet, 0x00, 0x06)             // RDLENGTH = 6
+	packet = append(packet, 0x00, 0x00, 192, 168, 1, 1)
+
+	msg, err := Parse(packet)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("Answers = %+v, want 1", msg.Answers)
+	}
+	rr := msg.Answers[0]
+	if rr.Name != "HOST1" {
+		t.Errorf("Name = %q, want HOST1", rr.Name)
+	}
+	if len(rr.RData) != 6 || rr.RData[2] != 192 {
+		t.Errorf("RData = %v", rr.RData)
+	}
+}
+
+func TestDecodeNodeStatus(t *testing.T) {
+	var rdata []byte
+	rdata = append(rdata, 2) // NUM_NAMES
+
+	entry1 := append(encodeRawName("HOST1", SuffixWorkstation), 0x00, 0x04)
+	entry2 := append(encodeRawName("HOST1", SuffixFileServer), 0x00, 0x04)
+	rdata = append(rdata, entry1...)
+	rdata = append(rdata, entry2...)
+	rdata = append(rdata, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF) // MAC
+
+	status, err := DecodeNodeStatus(rdata)
+	if err != nil {
+		t.Fatalf("DecodeNodeStatus() error = %v", err)
+	}
+	if len(status.Names) != 2 {
+		t.Fatalf("Names = %+v, want 2 entries", status.Names)
+	}
+	if status.Names[0].Name != "HOST1" || status.Names[1].Suffix != SuffixFileServer {
+		t.Fatalf("Names = %+v", status.Names)
+	}
+	want := [6]byte{0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+	if status.MAC != want {
+		t.Errorf("MAC = %x, want %x", status.MAC, want)
+	}
+}
+
+// encodeRawName 编码 NBSTAT 回答里 NUM_NAMES 表中一条记录的原始 (未做一级编码)
+// 15 字节名字 + 1 字节后缀, 与 buildNBNSQuery 里问题名字的一级编码不是同一种格式
+func encodeRawName(name string, suffix uint8) []byte {
+	var raw [16]byte
+	copy(raw[:15], name)
+	for i := len(name); i < 15; i++ {
+		raw[i] = ' '
+	}
+	raw[15] = suffix
+	return raw[:]
+}
+
+func TestParse_TooShort(t *testing.T) {
+	if _, err := Parse([]byte{0x01, 0x02}); err != ErrTooShort {
+		t.Fatalf("Parse() error = %v, want ErrTooShort", err)
+	}
+}
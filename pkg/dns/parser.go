@@ -14,17 +14,46 @@ var (
 	ErrInvalidHeader = errors.New("DNS invalid header")
 )
 
+// Mode 控制 Parser 在遇到截断/畸形记录时的行为
+type Mode int
+
+const (
+	// ModeLenient 尽量多解析: 某个资源记录解析失败时保留已经解析出的部分并停止
+	// 继续解析该部分, 不返回错误 (Parse 现有行为)
+	ModeLenient Mode = iota
+	// ModeStrict 任何截断/畸形记录都视为整个报文无效: Parse 直接返回错误, 并且
+	// 额外校验解析完成后的偏移量等于 len(data) (没有多余的尾部数据) 以及实际解析
+	// 出的记录数与头部声明的 QD/AN/NS/ARCount 一致。供 XDP-用户态对接时丢弃畸形
+	// 报文而不是转发一个残缺的解析结果
+	ModeStrict
+)
+
+// ParseOptions 配置 NewParserWithOptions 创建的 Parser 的解析行为
+type ParseOptions struct {
+	Mode            Mode // 严格/宽松模式, 零值为 ModeLenient
+	MaxRecords      int  // 单个部分 (AN/NS/AR) 允许解析的最大记录数, <=0 表示不限制, 用于限制对攻击者可控输入的处理量
+	SkipRData       bool // 为 true 时不保留 RData (只解析出 Name/Type/Class/TTL), 调用方只关心名字和类型时减少对原始报文缓冲区的引用
+	RequireQuestion bool // 为 true 时 QDCount 为 0 或一个问题都没解析出来视为错误
+}
+
 // Parser DNS 消息解析器
 type Parser struct {
 	maxNameLength int // 最大域名长度
 	maxLabels     int // 最大标签数
+	opts          ParseOptions
 }
 
-// NewParser 创建新的 DNS 解析器
+// NewParser 创建新的 DNS 解析器, 使用默认的宽松模式 (ModeLenient), 不限制记录数
 func NewParser() *Parser {
+	return NewParserWithOptions(ParseOptions{})
+}
+
+// NewParserWithOptions 按 opts 创建 Parser
+func NewParserWithOptions(opts ParseOptions) *Parser {
 	return &Parser{
 		maxNameLength: 255,
 		maxLabels:     128,
+		opts:          opts,
 	}
 }
 
@@ -49,6 +78,8 @@ func (p *Parser) Parse(data []byte) (*Message, error) {
 		ARCount: binary.BigEndian.Uint16(data[10:12]),
 	}
 
+	strict := p.opts.Mode == ModeStrict
+
 	// 解析问题部分
 	offset := 12
 	for i := uint16(0); i < msg.Header.QDCount; i++ {
@@ -59,20 +90,138 @@ func (p *Parser) Parse(data []byte) (*Message, error) {
 		msg.Questions = append(msg.Questions, q)
 		offset = newOffset
 	}
+	if p.opts.RequireQuestion && len(msg.Questions) == 0 {
+		return nil, ErrInvalidHeader
+	}
 
 	// 解析回答部分 (可选)
+	answerCount := 0
 	for i := uint16(0); i < msg.Header.ANCount && offset < len(data); i++ {
+		if p.recordLimitReached(answerCount) {
+			break
+		}
+		rr, newOffset, err := p.parseResourceRecord(data, offset)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			break // 宽松模式: 允许部分解析
+		}
+		msg.Answers = append(msg.Answers, p.maybeStripRData(rr))
+		offset = newOffset
+		answerCount++
+	}
+	if strict && len(msg.Answers) != int(msg.Header.ANCount) {
+		return nil, ErrTruncated
+	}
+
+	// 解析授权部分 (NS/SOA 等, 例如否定缓存需要的 SOA MINIMUM)
+	authorityCount := 0
+	for i := uint16(0); i < msg.Header.NSCount && offset < len(data); i++ {
+		if p.recordLimitReached(authorityCount) {
+			break
+		}
 		rr, newOffset, err := p.parseResourceRecord(data, offset)
 		if err != nil {
-			break // 允许部分解析
+			if strict {
+				return nil, err
+			}
+			break
 		}
-		msg.Answers = append(msg.Answers, rr)
+		msg.Authority = append(msg.Authority, p.maybeStripRData(rr))
 		offset = newOffset
+		authorityCount++
+	}
+	if strict && len(msg.Authority) != int(msg.Header.NSCount) {
+		return nil, ErrTruncated
+	}
+
+	// 解析附加部分, 其中类型为 OPT 的伪记录单独解码进 msg.EDNS, 其余进 msg.Additional
+	additionalCount := 0
+	for i := uint16(0); i < msg.Header.ARCount && offset < len(data); i++ {
+		if p.recordLimitReached(additionalCount) {
+			break
+		}
+		rr, newOffset, err := p.parseResourceRecord(data, offset)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			break
+		}
+		if rr.Type == TypeOPT {
+			edns, err := parseOPTRecord(rr)
+			if err != nil {
+				if strict {
+					return nil, err
+				}
+			} else {
+				msg.EDNS = edns
+			}
+		} else {
+			msg.Additional = append(msg.Additional, p.maybeStripRData(rr))
+		}
+		offset = newOffset
+		additionalCount++
+	}
+	if strict {
+		wantAdditional := int(msg.Header.ARCount)
+		if msg.EDNS != nil {
+			wantAdditional--
+		}
+		if len(msg.Additional) != wantAdditional {
+			return nil, ErrTruncated
+		}
+		if offset != len(data) {
+			return nil, ErrTruncated
+		}
 	}
 
 	return msg, nil
 }
 
+// recordLimitReached 判断已解析的记录数 (某一个部分内) 是否达到了 opts.MaxRecords
+func (p *Parser) recordLimitReached(parsed int) bool {
+	return p.opts.MaxRecords > 0 && parsed >= p.opts.MaxRecords
+}
+
+// maybeStripRData 按 opts.SkipRData 决定是否丢弃已解析记录的 RData, 只保留
+// Name/Type/Class/TTL, 减少对原始报文缓冲区的引用
+func (p *Parser) maybeStripRData(rr ResourceRecord) ResourceRecord {
+	if p.opts.SkipRData {
+		rr.RData = nil
+	}
+	return rr
+}
+
+// parseOPTRecord 将 OPT 伪记录解码为 EDNS 信息
+// OPT 记录对 CLASS/TTL 字段有特殊含义 (RFC 6891 §6.1.3):
+//   CLASS = requestor's UDP payload size
+//   TTL   = EXTENDED-RCODE(8) | VERSION(8) | DO(1) | Z(15)
+func parseOPTRecord(rr ResourceRecord) (*EDNS, error) {
+	edns := &EDNS{
+		UDPPayloadSize: rr.Class,
+		ExtendedRCode:  uint8(rr.TTL >> 24),
+		Version:        uint8(rr.TTL >> 16),
+		DO:             rr.TTL&0x8000 != 0,
+	}
+
+	rdata := rr.RData
+	for len(rdata) >= 4 {
+		code := binary.BigEndian.Uint16(rdata[0:2])
+		optLen := binary.BigEndian.Uint16(rdata[2:4])
+		if int(optLen) > len(rdata)-4 {
+			return nil, ErrTruncated
+		}
+		data := make([]byte, optLen)
+		copy(data, rdata[4:4+optLen])
+		edns.Options = append(edns.Options, EDNSOption{Code: code, Data: data})
+		rdata = rdata[4+optLen:]
+	}
+
+	return edns, nil
+}
+
 // parseQuestion 解析问题部分
 func (p *Parser) parseQuestion(data []byte, offset int) (Question, int, error) {
 	name, newOffset, err := p.parseName(data, offset)
@@ -117,6 +266,7 @@ func (p *Parser) parseResourceRecord(data []byte, offset int) (ResourceRecord, i
 	}
 
 	rr.RData = data[newOffset:rdataEnd]
+	rr.RDataOffset = newOffset
 	return rr, rdataEnd, nil
 }
 
@@ -0,0 +1,124 @@
+package dns
+
+import "testing"
+
+func buildMsgWithAuthority(t *testing.T) []byte {
+	t.Helper()
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeA, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 60, RData: []byte{1, 2, 3, 4}},
+		},
+		Authority: []ResourceRecord{
+			{Name: "example.com", Type: TypeSOA, Class: ClassIN, TTL: 3600,
+				RData: EncodeSOARData("ns1.example.com", "hostmaster.example.com", 1, 3600, 600, 604800, 300)},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	return raw
+}
+
+func TestParse_PopulatesAuthority(t *testing.T) {
+	raw := buildMsgWithAuthority(t)
+
+	msg, err := NewParser().Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(msg.Authority) != 1 || msg.Authority[0].Type != TypeSOA {
+		t.Fatalf("Authority = %+v, want a single SOA record", msg.Authority)
+	}
+}
+
+func TestParse_LenientMode_AllowsPartialAnswers(t *testing.T) {
+	raw := buildMsgWithAuthority(t)
+	truncated := raw[:len(raw)-5] // 截断掉 SOA 记录的一部分
+
+	msg, err := NewParserWithOptions(ParseOptions{Mode: ModeLenient}).Parse(truncated)
+	if err != nil {
+		t.Fatalf("Parse() in ModeLenient should not error on truncation, got %v", err)
+	}
+	if len(msg.Authority) != 0 {
+		t.Fatalf("Authority = %+v, want empty (truncated record dropped)", msg.Authority)
+	}
+}
+
+func TestParse_StrictMode_RejectsTruncation(t *testing.T) {
+	raw := buildMsgWithAuthority(t)
+	truncated := raw[:len(raw)-5]
+
+	if _, err := NewParserWithOptions(ParseOptions{Mode: ModeStrict}).Parse(truncated); err == nil {
+		t.Fatal("Parse() in ModeStrict should return an error on truncated input")
+	}
+}
+
+func TestParse_StrictMode_RejectsTrailingGarbage(t *testing.T) {
+	raw := buildMsgWithAuthority(t)
+	padded := append(append([]byte(nil), raw...), 0xFF, 0xFF)
+
+	if _, err := NewParserWithOptions(ParseOptions{Mode: ModeStrict}).Parse(padded); err == nil {
+		t.Fatal("Parse() in ModeStrict should reject trailing garbage after the last record")
+	}
+}
+
+func TestParse_StrictMode_AcceptsWellFormedMessage(t *testing.T) {
+	raw := buildMsgWithAuthority(t)
+
+	if _, err := NewParserWithOptions(ParseOptions{Mode: ModeStrict}).Parse(raw); err != nil {
+		t.Fatalf("Parse() in ModeStrict on a well-formed message error = %v", err)
+	}
+}
+
+func TestParse_MaxRecordsCapsParsedCount(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeA, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 60, RData: []byte{1, 1, 1, 1}},
+			{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 60, RData: []byte{2, 2, 2, 2}},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	parsed, err := NewParserWithOptions(ParseOptions{MaxRecords: 1}).Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(parsed.Answers) != 1 {
+		t.Fatalf("Answers = %+v, want 1 record (bounded by MaxRecords)", parsed.Answers)
+	}
+}
+
+func TestParse_SkipRData_DropsRecordData(t *testing.T) {
+	raw := buildMsgWithAuthority(t)
+
+	parsed, err := NewParserWithOptions(ParseOptions{SkipRData: true}).Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed.Answers[0].RData != nil {
+		t.Errorf("Answers[0].RData = %v, want nil", parsed.Answers[0].RData)
+	}
+	if parsed.Answers[0].Type != TypeA {
+		t.Errorf("Answers[0].Type = %d, want TypeA", parsed.Answers[0].Type)
+	}
+}
+
+func TestParse_RequireQuestion_RejectsQuestionlessMessage(t *testing.T) {
+	msg := &Message{Header: Header{ID: 1, Flags: 0x8180}}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	if _, err := NewParserWithOptions(ParseOptions{RequireQuestion: true}).Parse(raw); err == nil {
+		t.Fatal("Parse() with RequireQuestion should reject a message with no questions")
+	}
+}
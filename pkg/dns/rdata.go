@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// ErrUnsupportedType DecodeRData 不认识的记录类型
+var ErrUnsupportedType = errors.New("dns: unsupported record type for DecodeRData")
+
+// MXRecord 解析后的 MX 记录 RDATA
+type MXRecord struct {
+	Pref uint16 // PREFERENCE
+	MX   string // EXCHANGE
+}
+
+// SOARecord 解析后的 SOA 记录 RDATA
+type SOARecord struct {
+	NS      string // MNAME
+	Mbox    string // RNAME
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+// SRVRecord 解析后的 SRV 记录 RDATA (RFC 2782)
+type SRVRecord struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// CAARecord 解析后的 CAA 记录 RDATA (RFC 6844)
+type CAARecord struct {
+	Flag  uint8
+	Tag   string
+	Value string
+}
+
+// parseNameAt 以 data 为完整报文, 从绝对偏移 off 处解析一个域名, 是对
+// Parser.parseName 的薄封装, 供 DecodeRData 解析 RDATA 内部引用压缩指针
+// 指回报文更早位置的域名字段 (如 MX/SOA/SRV 的目标域名)
+func parseNameAt(data []byte, off int) (string, int, error) {
+	p := NewParser()
+	return p.parseName(data, off)
+}
+
+// DecodeRData 按 rr.Type 把 rr.RData 解码为具体的记录内容, msg 必须是 rr 所属
+// 的完整原始报文 (用于解析 RDATA 内部可能指回报文更早位置的压缩指针, 见
+// ResourceRecord.RDataOffset), 未经由 Parser 构造、RDataOffset 为零值的记录
+// 只要其 RDATA 内部不包含压缩指针同样可以正确解码。
+//
+// 返回值按类型分别是: A/AAAA -> net.IP, CNAME/NS/PTR -> string, MX -> MXRecord,
+// SOA -> SOARecord, SRV -> SRVRecord, CAA -> CAARecord, TXT -> []string。
+// 遇到不支持的类型返回 ErrUnsupportedType。
+func DecodeRData(rr *ResourceRecord, msg []byte) (interface{}, error) {
+	// 报文头部占据偏移 0-11, 解析出的 RDATA 不可能从 0 开始; RDataOffset 为零值
+	// 的记录直接在 rr.RData 内解码
+	if rr.RDataOffset == 0 {
+		msg = rr.RData
+	}
+
+	switch rr.Type {
+	case TypeA:
+		if len(rr.RData) != 4 {
+			return nil, ErrTruncated
+		}
+		return net.IP(append([]byte(nil), rr.RData...)), nil
+
+	case TypeAAAA:
+		if len(rr.RData) != 16 {
+			return nil, ErrTruncated
+		}
+		return net.IP(append([]byte(nil), rr.RData...)), nil
+
+	case TypeCNAME, TypeNS, TypePTR:
+		name, _, err := parseNameAt(msg, rr.RDataOffset)
+		if err != nil {
+			return nil, err
+		}
+		return name, nil
+
+	case TypeMX:
+		if len(rr.RData) < 3 {
+			return nil, ErrTruncated
+		}
+		pref := binary.BigEndian.Uint16(rr.RData[0:2])
+		mx, _, err := parseNameAt(msg, rr.RDataOffset+2)
+		if err != nil {
+			return nil, err
+		}
+		return MXRecord{Pref: pref, MX: mx}, nil
+
+	case TypeSOA:
+		ns, next, err := parseNameAt(msg, rr.RDataOffset)
+		if err != nil {
+			return nil, err
+		}
+		mbox, next, err := parseNameAt(msg, next)
+		if err != nil {
+			return nil, err
+		}
+		if next+20 > len(msg) {
+			return nil, ErrTruncated
+		}
+		return SOARecord{
+			NS:      ns,
+			Mbox:    mbox,
+			Serial:  binary.BigEndian.Uint32(msg[next : next+4]),
+			Refresh: binary.BigEndian.Uint32(msg[next+4 : next+8]),
+			Retry:   binary.BigEndian.Uint32(msg[next+8 : next+12]),
+			Expire:  binary.BigEndian.Uint32(msg[next+12 : next+16]),
+			Minimum: binary.BigEndian.Uint32(msg[next+16 : next+20]),
+		}, nil
+
+	case TypeSRV:
+		if len(rr.RData) < 7 {
+			return nil, ErrTruncated
+		}
+		target, _, err := parseNameAt(msg, rr.RDataOffset+6)
+		if err != nil {
+			return nil, err
+		}
+		return SRVRecord{
+			Priority: binary.BigEndian.Uint16(rr.RData[0:2]),
+			Weight:   binary.BigEndian.Uint16(rr.RData[2:4]),
+			Port:     binary.BigEndian.Uint16(rr.RData[4:6]),
+			Target:   target,
+		}, nil
+
+	case TypeCAA:
+		if len(rr.RData) < 2 {
+			return nil, ErrTruncated
+		}
+		tagLen := int(rr.RData[1])
+		if 2+tagLen > len(rr.RData) {
+			return nil, ErrTruncated
+		}
+		return CAARecord{
+			Flag:  rr.RData[0],
+			Tag:   string(rr.RData[2 : 2+tagLen]),
+			Value: string(rr.RData[2+tagLen:]),
+		}, nil
+
+	case TypeTXT:
+		var strs []string
+		rdata := rr.RData
+		for len(rdata) > 0 {
+			n := int(rdata[0])
+			if 1+n > len(rdata) {
+				return nil, ErrTruncated
+			}
+			strs = append(strs, string(rdata[1:1+n]))
+			rdata = rdata[1+n:]
+		}
+		return strs, nil
+
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
@@ -0,0 +1,170 @@
+package dns
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func decodeFirstAnswer(t *testing.T, raw []byte) (ResourceRecord, interface{}) {
+	t.Helper()
+	msg, err := NewParser().Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("Answers = %+v, want 1", msg.Answers)
+	}
+	rr := msg.Answers[0]
+	decoded, err := DecodeRData(&rr, raw)
+	if err != nil {
+		t.Fatalf("DecodeRData() error = %v", err)
+	}
+	return rr, decoded
+}
+
+func TestDecodeRData_A(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeA, QClass: ClassIN}},
+		Answers:   []ResourceRecord{{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 60, RData: net.IPv4(1, 2, 3, 4).To4()}},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	ip, ok := decoded.(net.IP)
+	if !ok || !ip.Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Fatalf("decoded = %#v, want 1.2.3.4", decoded)
+	}
+}
+
+func TestDecodeRData_CNAME_FollowsCompressionPointer(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "target.example.com", QType: TypeA, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			// RDATA 只有一个指回问题部分 target.example.com (偏移 12) 的压缩指针
+			{Name: "www.example.com", Type: TypeCNAME, Class: ClassIN, TTL: 60, RData: []byte{0xC0, 12}},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	if decoded.(string) != "target.example.com" {
+		t.Fatalf("decoded = %v, want target.example.com", decoded)
+	}
+}
+
+func TestDecodeRData_MX(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeMX, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeMX, Class: ClassIN, TTL: 60, RData: EncodeMXRData(10, "mail.example.com")},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	want := MXRecord{Pref: 10, MX: "mail.example.com"}
+	if decoded.(MXRecord) != want {
+		t.Fatalf("decoded = %+v, want %+v", decoded, want)
+	}
+}
+
+func TestDecodeRData_SOA(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeSOA, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeSOA, Class: ClassIN, TTL: 60,
+				RData: EncodeSOARData("ns1.example.com", "hostmaster.example.com", 2024010100, 3600, 600, 604800, 300)},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	want := SOARecord{NS: "ns1.example.com", Mbox: "hostmaster.example.com", Serial: 2024010100, Refresh: 3600, Retry: 600, Expire: 604800, Minimum: 300}
+	if decoded.(SOARecord) != want {
+		t.Fatalf("decoded = %+v, want %+v", decoded, want)
+	}
+}
+
+func TestDecodeRData_SRV(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "_sip._tcp.example.com", QType: TypeSRV, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "_sip._tcp.example.com", Type: TypeSRV, Class: ClassIN, TTL: 60, RData: EncodeSRVRData(10, 20, 5060, "sip.example.com")},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	want := SRVRecord{Priority: 10, Weight: 20, Port: 5060, Target: "sip.example.com"}
+	if decoded.(SRVRecord) != want {
+		t.Fatalf("decoded = %+v, want %+v", decoded, want)
+	}
+}
+
+func TestDecodeRData_CAA(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeCAA, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeCAA, Class: ClassIN, TTL: 60, RData: EncodeCAARData(0, "issue", "letsencrypt.org")},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	want := CAARecord{Flag: 0, Tag: "issue", Value: "letsencrypt.org"}
+	if decoded.(CAARecord) != want {
+		t.Fatalf("decoded = %+v, want %+v", decoded, want)
+	}
+}
+
+func TestDecodeRData_TXT_MultipleStrings(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 1, Flags: 0x8180},
+		Questions: []Question{{Name: "example.com", QType: TypeTXT, QClass: ClassIN}},
+		Answers: []ResourceRecord{
+			{Name: "example.com", Type: TypeTXT, Class: ClassIN, TTL: 60, RData: append(EncodeTXTRData("hello"), EncodeTXTRData("world")...)},
+		},
+	}
+	raw, err := Pack(msg)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	_, decoded := decodeFirstAnswer(t, raw)
+	want := []string{"hello", "world"}
+	if !reflect.DeepEqual(decoded.([]string), want) {
+		t.Fatalf("decoded = %v, want %v", decoded, want)
+	}
+}
+
+func TestDecodeRData_UnsupportedType(t *testing.T) {
+	rr := &ResourceRecord{Type: TypeSVCB, RData: []byte{0, 1}}
+	if _, err := DecodeRData(rr, nil); err != ErrUnsupportedType {
+		t.Fatalf("DecodeRData() error = %v, want ErrUnsupportedType", err)
+	}
+}
@@ -1,14 +1,48 @@
 package dns
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"net"
 )
 
+// 负载大小边界 (RFC 6891 §6.2.3 建议值)
+const (
+	noEDNSMaxSize     = 512  // 没有 OPT 记录时的传统 UDP 上限
+	minEDNSPayload    = 512  // 客户端声明值的下限
+	maxEDNSPayload    = 4096 // 客户端声明值的上限, 避免 IP 分片放大攻击
+	serverEDNSPayload = 1232 // 本服务端响应中通告的 UDP 负载大小
+)
+
+// cookieSecret 是服务端 Cookie (RFC 7873) 的加盐密钥, 进程启动时随机生成一次
+var cookieSecret = newCookieSecret()
+
+func newCookieSecret() []byte {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		// 极端情况下退化为固定密钥, 仍能生成语法正确的响应
+		copy(secret, []byte("xdp-dns-cookie-!"))
+	}
+	return secret
+}
+
+// serverCookie 计算与客户端 Cookie 绑定的服务端 Cookie, 截断到 8 字节 (RFC 7873 §4)
+func serverCookie(clientCookie []byte) []byte {
+	h := sha256.New()
+	h.Write(cookieSecret)
+	h.Write(clientCookie)
+	sum := h.Sum(nil)
+	return sum[:8]
+}
+
 // ResponseBuilder DNS 响应构建器
 type ResponseBuilder struct {
-	buffer []byte
-	offset int
+	buffer      []byte
+	offset      int
+	ancount     uint16
+	arcount     uint16
+	answerMarks []int // 每条答案记录起始偏移, 用于按记录边界截断
 }
 
 // NewResponseBuilder 创建响应构建器
@@ -32,7 +66,7 @@ func BuildNXDomainResponse(query *Message) []byte {
 	rb.writeHeader(query.Header.ID, flags, 1, 0, 0, 0)
 	rb.writeQuestion(query.Questions[0])
 
-	return rb.buffer
+	return rb.finish(query)
 }
 
 // BuildRefusedResponse 构建 REFUSED 响应
@@ -49,7 +83,24 @@ func BuildRefusedResponse(query *Message) []byte {
 	rb.writeHeader(query.Header.ID, flags, 1, 0, 0, 0)
 	rb.writeQuestion(query.Questions[0])
 
-	return rb.buffer
+	return rb.finish(query)
+}
+
+// BuildServFailResponse 构建 SERVFAIL 响应, 用于规则未命中且暂无上游可转发的场景
+func BuildServFailResponse(query *Message) []byte {
+	if query == nil || len(query.Questions) == 0 {
+		return nil
+	}
+
+	rb := NewResponseBuilder(512)
+
+	// 设置响应标志: QR=1, RD=1, RA=1, RCODE=2 (SERVFAIL)
+	flags := uint16(0x8180 | uint16(RCodeServerFailure))
+
+	rb.writeHeader(query.Header.ID, flags, 1, 0, 0, 0)
+	rb.writeQuestion(query.Questions[0])
+
+	return rb.finish(query)
 }
 
 // BuildAResponse 构建 A 记录响应
@@ -72,7 +123,7 @@ func BuildAResponse(query *Message, ip net.IP, ttl uint32) []byte {
 	rb.writeQuestion(query.Questions[0])
 	rb.writeARecord(query.Questions[0].Name, ip4, ttl)
 
-	return rb.buffer
+	return rb.finish(query)
 }
 
 // BuildAAAAResponse 构建 AAAA 记录响应
@@ -93,7 +144,25 @@ func BuildAAAAResponse(query *Message, ip net.IP, ttl uint32) []byte {
 	rb.writeQuestion(query.Questions[0])
 	rb.writeAAAARecord(query.Questions[0].Name, ip6, ttl)
 
-	return rb.buffer
+	return rb.finish(query)
+}
+
+// BuildPTRResponse 构建 PTR 记录响应, hostname 是规则为反向解析 CIDR 配置的主机名
+func BuildPTRResponse(query *Message, hostname string, ttl uint32) []byte {
+	if query == nil || len(query.Questions) == 0 || hostname == "" {
+		return nil
+	}
+
+	rb := NewResponseBuilder(512)
+
+	// 设置响应标志: QR=1, AA=1, RD=1, RA=1, RCODE=0
+	flags := uint16(0x8580)
+
+	rb.writeHeader(query.Header.ID, flags, 1, 1, 0, 0)
+	rb.writeQuestion(query.Questions[0])
+	rb.writeRecord(query.Questions[0].Name, TypePTR, ttl, EncodePTRRData(hostname))
+
+	return rb.finish(query)
 }
 
 // writeHeader 写入 DNS 头部
@@ -107,6 +176,8 @@ func (rb *ResponseBuilder) writeHeader(id, flags uint16, qd, an, ns, ar uint16)
 	binary.BigEndian.PutUint16(header[10:12], ar)
 	rb.buffer = append(rb.buffer, header...)
 	rb.offset += 12
+	rb.ancount = an
+	rb.arcount = ar
 }
 
 // writeQuestion 写入问题部分
@@ -121,27 +192,33 @@ func (rb *ResponseBuilder) writeQuestion(q Question) {
 
 // writeName 写入域名
 func (rb *ResponseBuilder) writeName(name string) {
+	encoded := encodeName(name)
+	rb.buffer = append(rb.buffer, encoded...)
+	rb.offset += len(encoded)
+}
+
+// encodeName 把域名编码为长度前缀标签序列 (不做压缩指针), 供 writeName 以及
+// 需要把域名塞进 RDATA 的记录类型 (CNAME/PTR/MX/SRV/SVCB 等) 复用
+func encodeName(name string) []byte {
 	if name == "" {
-		rb.buffer = append(rb.buffer, 0)
-		rb.offset++
-		return
+		return []byte{0}
 	}
 
-	labels := splitDomainName(name)
-	for _, label := range labels {
+	var out []byte
+	for _, label := range splitDomainName(name) {
 		if len(label) > 63 {
 			label = label[:63]
 		}
-		rb.buffer = append(rb.buffer, byte(len(label)))
-		rb.buffer = append(rb.buffer, []byte(label)...)
-		rb.offset += 1 + len(label)
+		out = append(out, byte(len(label)))
+		out = append(out, []byte(label)...)
 	}
-	rb.buffer = append(rb.buffer, 0)
-	rb.offset++
+	out = append(out, 0)
+	return out
 }
 
 // writeARecord 写入 A 记录
 func (rb *ResponseBuilder) writeARecord(name string, ip net.IP, ttl uint32) {
+	rb.answerMarks = append(rb.answerMarks, len(rb.buffer))
 	rb.writeName(name)
 	record := make([]byte, 10)
 	binary.BigEndian.PutUint16(record[0:2], TypeA)
@@ -155,6 +232,7 @@ func (rb *ResponseBuilder) writeARecord(name string, ip net.IP, ttl uint32) {
 
 // writeAAAARecord 写入 AAAA 记录
 func (rb *ResponseBuilder) writeAAAARecord(name string, ip net.IP, ttl uint32) {
+	rb.answerMarks = append(rb.answerMarks, len(rb.buffer))
 	rb.writeName(name)
 	record := make([]byte, 10)
 	binary.BigEndian.PutUint16(record[0:2], TypeAAAA)
@@ -166,6 +244,272 @@ func (rb *ResponseBuilder) writeAAAARecord(name string, ip net.IP, ttl uint32) {
 	rb.offset += 26
 }
 
+// writeRecord 写入一条通用资源记录: NAME + TYPE + CLASS + TTL + RDLENGTH + RDATA,
+// 供 $dnsrewrite 风格的 MX/TXT/PTR/SRV/SVCB/HTTPS 合成答案复用
+func (rb *ResponseBuilder) writeRecord(name string, rtype uint16, ttl uint32, rdata []byte) {
+	rb.answerMarks = append(rb.answerMarks, len(rb.buffer))
+	rb.writeName(name)
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:2], rtype)
+	binary.BigEndian.PutUint16(header[2:4], ClassIN)
+	binary.BigEndian.PutUint32(header[4:8], ttl)
+	rb.buffer = append(rb.buffer, header...)
+	rb.offset += 8
+
+	rdlen := make([]byte, 2)
+	binary.BigEndian.PutUint16(rdlen, uint16(len(rdata)))
+	rb.buffer = append(rb.buffer, rdlen...)
+	rb.buffer = append(rb.buffer, rdata...)
+	rb.offset += 2 + len(rdata)
+}
+
+// EncodeCNAMERData / EncodePTRRData 编码一个域名类型的 RDATA (未做压缩指针)
+func EncodeCNAMERData(target string) []byte { return encodeName(target) }
+func EncodePTRRData(target string) []byte   { return encodeName(target) }
+
+// EncodeMXRData 编码 MX 记录的 RDATA: PREFERENCE(2) + EXCHANGE
+func EncodeMXRData(preference uint16, exchange string) []byte {
+	rdata := make([]byte, 2)
+	binary.BigEndian.PutUint16(rdata, preference)
+	return append(rdata, encodeName(exchange)...)
+}
+
+// EncodeTXTRData 编码 TXT 记录的 RDATA: 一个长度前缀的字符串 (超过 255 字节按
+// RFC 1035 §3.3.14 拆成多个 <character-string>)
+func EncodeTXTRData(text string) []byte {
+	var rdata []byte
+	for len(text) > 255 {
+		rdata = append(rdata, 255)
+		rdata = append(rdata, text[:255]...)
+		text = text[255:]
+	}
+	rdata = append(rdata, byte(len(text)))
+	rdata = append(rdata, text...)
+	return rdata
+}
+
+// EncodeSRVRData 编码 SRV 记录的 RDATA: PRIORITY(2) + WEIGHT(2) + PORT(2) + TARGET
+func EncodeSRVRData(priority, weight, port uint16, target string) []byte {
+	rdata := make([]byte, 6)
+	binary.BigEndian.PutUint16(rdata[0:2], priority)
+	binary.BigEndian.PutUint16(rdata[2:4], weight)
+	binary.BigEndian.PutUint16(rdata[4:6], port)
+	return append(rdata, encodeName(target)...)
+}
+
+// EncodeSVCBRData 编码 SVCB/HTTPS (RFC 9460) 记录的 RDATA: SvcPriority(2) + TargetName
+//
+// 简化实现: 不支持 SvcParamKey/SvcParamValue (alpn、port 等), 本仓库目前没有需要
+// 这些参数的场景; 需要时可在这里追加 params 编码
+func EncodeSVCBRData(priority uint16, target string) []byte {
+	rdata := make([]byte, 2)
+	binary.BigEndian.PutUint16(rdata, priority)
+	return append(rdata, encodeName(target)...)
+}
+
+// EncodeSOARData 编码 SOA 记录的 RDATA: MNAME + RNAME + SERIAL(4) + REFRESH(4) +
+// RETRY(4) + EXPIRE(4) + MINIMUM(4)
+func EncodeSOARData(ns, mbox string, serial, refresh, retry, expire, minimum uint32) []byte {
+	rdata := encodeName(ns)
+	rdata = append(rdata, encodeName(mbox)...)
+	tail := make([]byte, 20)
+	binary.BigEndian.PutUint32(tail[0:4], serial)
+	binary.BigEndian.PutUint32(tail[4:8], refresh)
+	binary.BigEndian.PutUint32(tail[8:12], retry)
+	binary.BigEndian.PutUint32(tail[12:16], expire)
+	binary.BigEndian.PutUint32(tail[16:20], minimum)
+	return append(rdata, tail...)
+}
+
+// EncodeCAARData 编码 CAA (RFC 6844) 记录的 RDATA: FLAGS(1) + TAG 长度前缀 + VALUE
+func EncodeCAARData(flag uint8, tag, value string) []byte {
+	rdata := make([]byte, 2, 2+len(tag)+len(value))
+	rdata[0] = flag
+	rdata[1] = byte(len(tag))
+	rdata = append(rdata, tag...)
+	rdata = append(rdata, value...)
+	return rdata
+}
+
+// RewriteRecord 描述一条待合成的资源记录, 由 filter 的 rewrite 动作产出,
+// 交给 BuildRewriteResponse 写入响应; Name 为空时使用查询本身的问题域名
+type RewriteRecord struct {
+	Name  string
+	Type  uint16
+	TTL   uint32
+	RData []byte
+}
+
+// BuildRewriteResponse 根据 $dnsrewrite 风格的规则动作构建响应:
+//   - noData 为 true 时返回 NOERROR 且不带任何 Answer 的响应, 优先级最高
+//   - rcode 非 RCodeNoError 时返回该 RCODE 且不带任何 Answer 的响应 (不与 records 同时生效)
+//   - 否则把 records 依次写入 Answer 部分 (由调用方保证记录的 Name/Type 与查询类型匹配)
+func BuildRewriteResponse(query *Message, rcode uint16, noData bool, records []RewriteRecord) []byte {
+	if query == nil || len(query.Questions) == 0 {
+		return nil
+	}
+
+	rb := NewResponseBuilder(512)
+
+	if noData {
+		rcode = RCodeNoError
+		records = nil
+	} else if rcode != RCodeNoError {
+		records = nil
+	}
+
+	flags := uint16(0x8180 | rcode)
+	rb.writeHeader(query.Header.ID, flags, 1, uint16(len(records)), 0, 0)
+	rb.writeQuestion(query.Questions[0])
+
+	for _, rec := range records {
+		name := rec.Name
+		if name == "" {
+			name = query.Questions[0].Name
+		}
+		rb.writeRecord(name, rec.Type, rec.TTL, rec.RData)
+	}
+
+	return rb.finish(query)
+}
+
+// maxResponseSize 返回本次响应允许的最大负载大小: 没有 OPT 记录时是传统的 512 字节,
+// 否则取客户端声明的 UDP 负载大小, 并夹在 [minEDNSPayload, maxEDNSPayload] 之间
+func maxResponseSize(query *Message) int {
+	if query == nil || query.EDNS == nil {
+		return noEDNSMaxSize
+	}
+
+	size := int(query.EDNS.UDPPayloadSize)
+	if size < minEDNSPayload {
+		size = minEDNSPayload
+	}
+	if size > maxEDNSPayload {
+		size = maxEDNSPayload
+	}
+	return size
+}
+
+// buildEDNSOptions 根据请求回显 NSID, 并计算/回显 DNS Cookie (RFC 7873)
+func buildEDNSOptions(edns *EDNS) []byte {
+	var out []byte
+
+	if nsid := edns.GetOption(EDNSOptionNSID); nsid != nil {
+		out = append(out, encodeEDNSOption(EDNSOptionNSID, nsid.Data)...)
+	}
+
+	if cookie := edns.Cookie(); len(cookie) >= 8 {
+		clientCookie := cookie[:8]
+		resp := make([]byte, 0, 16)
+		resp = append(resp, clientCookie...)
+		resp = append(resp, serverCookie(clientCookie)...)
+		out = append(out, encodeEDNSOption(EDNSOptionCookie, resp)...)
+	}
+
+	if ecs := edns.ECS(); ecs != nil {
+		out = append(out, encodeECSOption(ecs)...)
+	}
+
+	return out
+}
+
+// encodeECSOption 把客户端声明的 ECS 回显为响应选项: FAMILY(2) + SOURCE PREFIX-LENGTH(1)
+// 原样回显 + SCOPE PREFIX-LENGTH(1) 固定为 0 + ADDRESS, 表示本服务端合成的答案不区分子网
+// (RFC 7871 §7.1.2: SCOPE PREFIX-LENGTH 为 0 意味着该答案对请求中声明的整个子网都适用)
+func encodeECSOption(ecs *ECS) []byte {
+	family := uint16(ecsFamilyIPv4)
+	addr := ecs.Address.To4()
+	if addr == nil {
+		family = ecsFamilyIPv6
+		addr = ecs.Address.To16()
+	}
+
+	addrLen := (int(ecs.SourcePrefixLen) + 7) / 8
+	if addrLen > len(addr) {
+		addrLen = len(addr)
+	}
+
+	data := make([]byte, 4+addrLen)
+	binary.BigEndian.PutUint16(data[0:2], family)
+	data[2] = ecs.SourcePrefixLen
+	data[3] = 0 // SCOPE PREFIX-LENGTH = 0
+	copy(data[4:], addr[:addrLen])
+
+	return encodeEDNSOption(EDNSOptionECS, data)
+}
+
+// encodeEDNSOption 编码单个 EDNS 选项 TLV: CODE(2) + LENGTH(2) + DATA
+func encodeEDNSOption(code uint16, data []byte) []byte {
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint16(buf[0:2], code)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(data)))
+	copy(buf[4:], data)
+	return buf
+}
+
+// writeOPT 在附加部分追加 OPT 伪记录, 回显 NSID/Cookie 并通告服务端负载大小
+func (rb *ResponseBuilder) writeOPT(query *Message) {
+	if query == nil || query.EDNS == nil {
+		return
+	}
+
+	options := buildEDNSOptions(query.EDNS)
+
+	rb.writeName("") // OPT 记录 NAME 固定为根域名
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:2], TypeOPT)
+	binary.BigEndian.PutUint16(header[2:4], serverEDNSPayload) // CLASS = 服务端负载大小
+
+	var ttl uint32
+	if query.EDNS.DO {
+		ttl |= 0x8000
+	}
+	binary.BigEndian.PutUint32(header[4:8], ttl)
+	rb.buffer = append(rb.buffer, header...)
+
+	rdlen := make([]byte, 2)
+	binary.BigEndian.PutUint16(rdlen, uint16(len(options)))
+	rb.buffer = append(rb.buffer, rdlen...)
+	rb.buffer = append(rb.buffer, options...)
+
+	rb.arcount++
+}
+
+// finish 按客户端协商的负载大小截断答案 (必要时设置 TC), 追加 OPT 记录,
+// 并回填头部中的 ANCOUNT/ARCOUNT/flags
+func (rb *ResponseBuilder) finish(query *Message) []byte {
+	maxSize := maxResponseSize(query)
+
+	optSize := 0
+	if query != nil && query.EDNS != nil {
+		optSize = 11 + len(buildEDNSOptions(query.EDNS)) // NAME(1) + TYPE/CLASS/TTL(8) + RDLENGTH(2)
+	}
+
+	truncated := false
+	for len(rb.buffer)+optSize > maxSize && len(rb.answerMarks) > 0 {
+		last := rb.answerMarks[len(rb.answerMarks)-1]
+		rb.buffer = rb.buffer[:last]
+		rb.answerMarks = rb.answerMarks[:len(rb.answerMarks)-1]
+		rb.ancount--
+		truncated = true
+	}
+
+	if truncated {
+		flags := binary.BigEndian.Uint16(rb.buffer[2:4])
+		flags |= 0x0200 // TC
+		binary.BigEndian.PutUint16(rb.buffer[2:4], flags)
+	}
+
+	binary.BigEndian.PutUint16(rb.buffer[6:8], rb.ancount)
+
+	rb.writeOPT(query)
+
+	binary.BigEndian.PutUint16(rb.buffer[10:12], rb.arcount)
+
+	return rb.buffer
+}
+
 // splitDomainName 分割域名为标签
 func splitDomainName(name string) []string {
 	var labels []string
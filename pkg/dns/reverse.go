@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParsePTRName 把一个 in-addr.arpa/ip6.arpa 反向解析域名解码回 net.IP, 主要供
+// 日志/调试把 PTR 查询还原成可读地址使用 (与 pkg/filter 按正向方式由 CIDR 生成
+// 这些域名互为逆操作)。不是合法的反向解析域名格式时返回 (nil, false)。
+func ParsePTRName(name string) (net.IP, bool) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		return parseV4PTRLabels(strings.TrimSuffix(name, ".in-addr.arpa"))
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		return parseV6PTRLabels(strings.TrimSuffix(name, ".ip6.arpa"))
+	default:
+		return nil, false
+	}
+}
+
+// parseV4PTRLabels 解析 "1.0.0.10" 形式的标签 (最低位字节在前), 还原为网络序 IPv4
+func parseV4PTRLabels(labels string) (net.IP, bool) {
+	parts := strings.Split(labels, ".")
+	if len(parts) != 4 {
+		return nil, false
+	}
+
+	ip := make(net.IP, 4)
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 || v > 255 {
+			return nil, false
+		}
+		ip[len(parts)-1-i] = byte(v)
+	}
+	return ip, true
+}
+
+// parseV6PTRLabels 解析 32 个半字节标签 (最低位半字节在前), 还原为网络序 IPv6
+func parseV6PTRLabels(labels string) (net.IP, bool) {
+	nibbles := strings.Split(labels, ".")
+	if len(nibbles) != 32 {
+		return nil, false
+	}
+
+	ip := make(net.IP, 16)
+	for i, n := range nibbles {
+		if len(n) != 1 {
+			return nil, false
+		}
+		v, err := strconv.ParseUint(n, 16, 8)
+		if err != nil {
+			return nil, false
+		}
+
+		byteIdx := 15 - i/2
+		if i%2 == 0 {
+			ip[byteIdx] |= byte(v) // 低半字节先出现
+		} else {
+			ip[byteIdx] |= byte(v) << 4
+		}
+	}
+	return ip, true
+}
@@ -0,0 +1,60 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestParsePTRName_V4(t *testing.T) {
+	ip, ok := ParsePTRName("1.0.0.10.in-addr.arpa")
+	if !ok {
+		t.Fatal("ParsePTRName() ok = false, want true")
+	}
+	if got := ip.String(); got != "10.0.0.1" {
+		t.Fatalf("ParsePTRName() = %s, want 10.0.0.1", got)
+	}
+}
+
+func TestParsePTRName_V6(t *testing.T) {
+	// 2001:db8::1 的标准 PTR 名 (半字节从低位到高位, 补齐到 32 位)
+	name := "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"
+	ip, ok := ParsePTRName(name)
+	if !ok {
+		t.Fatal("ParsePTRName() ok = false, want true")
+	}
+	if got := ip.String(); got != "2001:db8::1" {
+		t.Fatalf("ParsePTRName() = %s, want 2001:db8::1", got)
+	}
+}
+
+func TestParsePTRName_Invalid(t *testing.T) {
+	if _, ok := ParsePTRName("example.com"); ok {
+		t.Fatal("ParsePTRName() on a forward name should return ok = false")
+	}
+}
+
+func TestMessage_PTRAddress(t *testing.T) {
+	msg := &Message{Questions: []Question{{Name: "1.0.0.10.in-addr.arpa", QType: TypePTR, QClass: ClassIN}}}
+	ip, ok := msg.PTRAddress()
+	if !ok || ip.String() != "10.0.0.1" {
+		t.Fatalf("PTRAddress() = (%v, %v), want (10.0.0.1, true)", ip, ok)
+	}
+}
+
+func TestBuildPTRResponse(t *testing.T) {
+	query := &Message{
+		Header:    Header{ID: 0x1234},
+		Questions: []Question{{Name: "1.0.0.10.in-addr.arpa", QType: TypePTR, QClass: ClassIN}},
+	}
+	resp := BuildPTRResponse(query, "host.example.com", 120)
+
+	msg, err := NewParser().Parse(resp)
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("Answers = %d, want 1", len(msg.Answers))
+	}
+	if msg.Answers[0].Type != TypePTR {
+		t.Fatalf("Answers[0].Type = %d, want TypePTR", msg.Answers[0].Type)
+	}
+}
@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func buildSimpleAQuery(domain string) *Message {
+	return &Message{
+		Header:    Header{ID: 0x1234},
+		Questions: []Question{{Name: domain, QType: TypeA, QClass: ClassIN}},
+	}
+}
+
+func TestBuildRewriteResponse_NoData(t *testing.T) {
+	query := buildSimpleAQuery("example.com")
+	resp := BuildRewriteResponse(query, RCodeNoError, true, []RewriteRecord{
+		{Type: TypeA, TTL: 60, RData: net.ParseIP("1.2.3.4").To4()},
+	})
+
+	ancount := binary.BigEndian.Uint16(resp[6:8])
+	if ancount != 0 {
+		t.Fatalf("NODATA response ancount = %d, want 0", ancount)
+	}
+	rcode := binary.BigEndian.Uint16(resp[2:4]) & 0x000F
+	if rcode != RCodeNoError {
+		t.Fatalf("NODATA response rcode = %d, want NOERROR", rcode)
+	}
+}
+
+func TestBuildRewriteResponse_ForcedRCode(t *testing.T) {
+	query := buildSimpleAQuery("example.com")
+	resp := BuildRewriteResponse(query, RCodeNXDomain, false, nil)
+
+	rcode := binary.BigEndian.Uint16(resp[2:4]) & 0x000F
+	if rcode != RCodeNXDomain {
+		t.Fatalf("rcode = %d, want NXDOMAIN", rcode)
+	}
+	if ancount := binary.BigEndian.Uint16(resp[6:8]); ancount != 0 {
+		t.Fatalf("ancount = %d, want 0", ancount)
+	}
+}
+
+func TestBuildRewriteResponse_MultipleRecordTypes(t *testing.T) {
+	query := buildSimpleAQuery("example.com")
+	records := []RewriteRecord{
+		{Type: TypeA, TTL: 60, RData: net.ParseIP("1.2.3.4").To4()},
+		{Type: TypeCNAME, TTL: 120, RData: EncodeCNAMERData("alias.example.com")},
+		{Type: TypeMX, TTL: 300, RData: EncodeMXRData(10, "mail.example.com")},
+		{Type: TypeTXT, TTL: 300, RData: EncodeTXTRData("v=spf1 -all")},
+		{Type: TypeSRV, TTL: 300, RData: EncodeSRVRData(1, 2, 443, "svc.example.com")},
+		{Type: TypeSVCB, TTL: 300, RData: EncodeSVCBRData(1, "svc.example.com")},
+	}
+
+	resp := BuildRewriteResponse(query, RCodeNoError, false, records)
+
+	ancount := binary.BigEndian.Uint16(resp[6:8])
+	if int(ancount) != len(records) {
+		t.Fatalf("ancount = %d, want %d", ancount, len(records))
+	}
+
+	parsed, err := NewParser().Parse(resp)
+	if err != nil {
+		t.Fatalf("reparse response: %v", err)
+	}
+	if len(parsed.Answers) != len(records) {
+		t.Fatalf("parsed %d answers, want %d", len(parsed.Answers), len(records))
+	}
+	for i, rec := range records {
+		if parsed.Answers[i].Type != rec.Type {
+			t.Errorf("answer %d type = %d, want %d", i, parsed.Answers[i].Type, rec.Type)
+		}
+	}
+}
+
+func TestEncodeTXTRData_SplitsLongStrings(t *testing.T) {
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = 'a'
+	}
+	rdata := EncodeTXTRData(string(long))
+
+	if rdata[0] != 255 {
+		t.Fatalf("first character-string length = %d, want 255", rdata[0])
+	}
+	secondLen := rdata[1+255]
+	if secondLen != 45 {
+		t.Fatalf("second character-string length = %d, want 45", secondLen)
+	}
+}
@@ -0,0 +1,266 @@
+// Package tcp 实现 DNS-over-TCP 回落数据通道 (RFC 1035 §4.2.2, RFC 7766)
+//
+// XDP 快速路径只处理 UDP 查询, 当 pkg/dns 构建的响应因超过客户端 EDNS 负载大小而
+// 被截断 (TC=1) 时, 符合规范的客户端会改用 TCP 向同一服务器重试。本包提供该重试
+// 所需的监听器, 并复用与 XDP Worker 相同的 filter.Engine.Check 规则集。
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/filter"
+	"xdp-dns/pkg/forwarder"
+	"xdp-dns/pkg/metrics"
+)
+
+// 单条 TCP 消息的长度前缀是 2 字节, 因此最大可达 65535 字节
+const maxTCPMessageSize = 65535
+
+var (
+	// ErrMessageTooLarge 消息长度前缀超出协议允许的范围
+	ErrMessageTooLarge = errors.New("dns/tcp: message exceeds maximum TCP frame size")
+)
+
+// Server DNS-over-TCP 回落服务
+type Server struct {
+	listener    net.Listener
+	engine      *filter.Engine
+	idleTimeout time.Duration
+}
+
+// NewServer 创建新的 DNS-over-TCP 服务, idleTimeout 为连接空闲超时时间,
+// 超时未收到下一条查询则关闭连接 (RFC 7766 §6.2.3 建议的行为)
+func NewServer(engine *filter.Engine, idleTimeout time.Duration) *Server {
+	if idleTimeout <= 0 {
+		idleTimeout = 30 * time.Second
+	}
+	return &Server{
+		engine:      engine,
+		idleTimeout: idleTimeout,
+	}
+}
+
+// ListenAndServe 在 addr 上监听并处理 DNS-over-TCP 连接, 阻塞直到 Close 被调用
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	log.Printf("DNS-over-TCP fallback listener started on %s", addr)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("dns/tcp: accept error: %v", err)
+			continue
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// ListenAndServeTLS 在 addr 上以 DNS-over-TLS (RFC 7858) 方式监听: 消息帧与
+// 明文 DNS-over-TCP 完全相同 (2 字节长度前缀 + DNS 报文), 唯一区别是连接本身
+// 套了一层 TLS, 因此直接复用 handleConn。tlsConfig.NextProtos 建议设为 []string{"dot"}
+// (RFC 7858 §9.2 ALPN 标识)
+func (s *Server) ListenAndServeTLS(addr string, tlsConfig *tls.Config) error {
+	ln, err := tls.Listen("tcp", addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	log.Printf("DNS-over-TLS listener started on %s", addr)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("dns/tcp: DoT accept error: %v", err)
+			continue
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close 停止监听, 不影响已经建立的连接
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn 处理单条连接上的多条流水线查询 (RFC 7766 §8 pipelining):
+// 客户端可以在收到上一条响应前发送下一条查询, 本实现按顺序读取并依次响应
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	metrics.IncTCPConnections()
+	defer metrics.DecTCPConnections()
+
+	srcIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+
+		query, err := readMessage(conn)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("dns/tcp: read error from %s: %v", srcIP, err)
+			}
+			return
+		}
+
+		metrics.IncTCPQueries()
+
+		response := Process(s.engine, query, srcIP)
+		if response == nil {
+			continue
+		}
+
+		if err := writeMessage(conn, response); err != nil {
+			log.Printf("dns/tcp: write error to %s: %v", srcIP, err)
+			return
+		}
+	}
+}
+
+// Process 解析一条查询, 经过给定 engine 的过滤规则并构建响应。TCP 回落监听器和
+// pkg/listener 的 DoH/DoT 前端共享这同一套决策与响应构建逻辑, 唯一的区别只是
+// "怎么把查询字节收上来、把响应字节发回去"。响应大小仍受 pkg/dns 构建器的上限约束:
+// 带 OPT 的查询最多 4096 字节, 不带 OPT 的查询最多 512 字节, 超出时答案按记录边界
+// 截断并设置 TC 位。
+func Process(engine *filter.Engine, raw []byte, srcIP string) []byte {
+	parser := dns.NewParser()
+	msg, err := parser.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	if len(msg.Questions) == 0 {
+		return dns.BuildRefusedResponse(msg)
+	}
+
+	action, rule := engine.Check(msg, srcIP)
+	buildQuery := withoutUDPBudget(msg)
+
+	switch action {
+	case filter.ActionBlock:
+		return dns.BuildNXDomainResponse(buildQuery)
+
+	case filter.ActionRedirect:
+		if rule == nil || rule.RedirectIP == nil {
+			return dns.BuildServFailResponse(buildQuery)
+		}
+		if msg.GetQueryType() == dns.TypeAAAA {
+			return dns.BuildAAAAResponse(buildQuery, rule.RedirectIP, rule.RedirectTTL)
+		}
+		return dns.BuildAResponse(buildQuery, rule.RedirectIP, rule.RedirectTTL)
+
+	case filter.ActionForward:
+		if rule == nil || len(rule.Forwarders) == 0 {
+			return dns.BuildServFailResponse(buildQuery)
+		}
+		response, err := forwardQuery(rule.Forwarders, raw)
+		if err != nil {
+			log.Printf("dns/tcp: forward to upstream failed: %v", err)
+			return dns.BuildServFailResponse(buildQuery)
+		}
+		return response
+
+	case filter.ActionAnswer:
+		if rule == nil || rule.LocalAnswer == nil {
+			return dns.BuildServFailResponse(buildQuery)
+		}
+		if msg.Questions[0].QClass != dns.ClassIN {
+			return dns.BuildRefusedResponse(buildQuery)
+		}
+		local := rule.LocalAnswer
+		switch {
+		case msg.GetQueryType() == dns.TypeAAAA && len(local.AAAA) > 0:
+			return dns.BuildAAAAResponse(buildQuery, local.NextAAAA(), local.TTL)
+		case msg.GetQueryType() == dns.TypeA && len(local.A) > 0:
+			return dns.BuildAResponse(buildQuery, local.NextA(), local.TTL)
+		case msg.GetQueryType() == dns.TypePTR && local.PTR != "":
+			return dns.BuildPTRResponse(buildQuery, local.PTR, local.TTL)
+		default:
+			// 本地区域里存在这个名字, 但没有与查询类型匹配的记录
+			return dns.BuildRewriteResponse(buildQuery, dns.RCodeNoError, true, nil)
+		}
+
+	default:
+		// ActionAllow/ActionLog: 本包没有默认上游, 只有 ActionForward 规则才经
+		// pkg/forwarder 转发, 其余放行的查询一律应答 SERVFAIL。
+		return dns.BuildServFailResponse(buildQuery)
+	}
+}
+
+// forwardQuery 把 filter.Upstream 转换为 forwarder 包自己的等价类型并发起一次竞速转发,
+// 两个包不互相依赖对方的类型, 避免 import 循环 (与 convertRateLimits 的约定一致)。
+func forwardQuery(upstreams []filter.Upstream, raw []byte) ([]byte, error) {
+	ups := make([]forwarder.Upstream, len(upstreams))
+	for i, u := range upstreams {
+		ups[i] = forwarder.Upstream{Addr: u.Addr, StartDelay: u.StartDelay}
+	}
+	fwd := forwarder.New(ups, forwarder.DefaultTimeout)
+	return fwd.Forward(context.Background(), raw)
+}
+
+// withoutUDPBudget 返回一份把 EDNS 负载大小设为 TCP 帧上限的消息副本, 避免响应
+// 构建器按照 UDP 场景下协商的小负载截断 TCP 响应; pkg/dns 的构建器会把这个值夹到
+// 4096 字节。查询不带 OPT 时原样返回 msg, 响应仍按 512 字节上限构建
+func withoutUDPBudget(msg *dns.Message) *dns.Message {
+	if msg.EDNS == nil {
+		return msg
+	}
+	clone := *msg
+	edns := *msg.EDNS
+	edns.UDPPayloadSize = maxTCPMessageSize
+	clone.EDNS = &edns
+	return &clone
+}
+
+// readMessage 读取一条 2 字节长度前缀 + 负载的 DNS-over-TCP 消息
+func readMessage(r io.Reader) ([]byte, error) {
+	var lengthBuf [2]byte
+	if _, err := io.ReadFull(r, lengthBuf[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint16(lengthBuf[:])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// writeMessage 以 2 字节长度前缀写出一条 DNS-over-TCP 消息
+func writeMessage(w io.Writer, payload []byte) error {
+	if len(payload) > maxTCPMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	var lengthBuf [2]byte
+	binary.BigEndian.PutUint16(lengthBuf[:], uint16(len(payload)))
+
+	if _, err := w.Write(lengthBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/filter"
+)
+
+// buildTestQuery 构建一个简单的 A 查询包, 复用 pkg/dns 的测试约定
+func buildTestQuery(domain string) []byte {
+	packet := []byte{
+		0x12, 0x34, // ID
+		0x01, 0x00, // Flags
+		0x00, 0x01, // QDCount = 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	start := 0
+	for i := 0; i <= len(domain); i++ {
+		if i == len(domain) || domain[i] == '.' {
+			packet = append(packet, byte(i-start))
+			packet = append(packet, []byte(domain[start:i])...)
+			start = i + 1
+		}
+	}
+	packet = append(packet, 0, 0x00, 0x01, 0x00, 0x01)
+	return packet
+}
+
+func TestReadWriteMessage_Roundtrip(t *testing.T) {
+	payload := buildTestQuery("example.com")
+
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, payload); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+
+	got, err := readMessage(&buf)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("readMessage() = %x, want %x", got, payload)
+	}
+}
+
+func TestServer_ProcessBlockedDomain(t *testing.T) {
+	engine, _ := filter.NewEngine("")
+	engine.AddRule(filter.Rule{
+		ID:      "block-ads",
+		Enabled: true,
+		Action:  filter.ActionBlock,
+		Domains: []string{"ads.example.com"},
+	})
+
+	srv := NewServer(engine, 0)
+	resp := Process(srv.engine, buildTestQuery("ads.example.com"), "192.168.1.1")
+	if resp == nil {
+		t.Fatal("process() returned nil response")
+	}
+
+	parser := dns.NewParser()
+	msg, err := parser.Parse(resp)
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+	if msg.GetRCode() != dns.RCodeNXDomain {
+		t.Errorf("RCODE = %d, want NXDOMAIN", msg.GetRCode())
+	}
+}
+
+func TestServer_ProcessLocalAnswer(t *testing.T) {
+	engine, _ := filter.NewEngine("")
+	engine.AddRule(filter.Rule{
+		ID:      "internal-host",
+		Enabled: true,
+		Action:  filter.ActionAnswer,
+		Domains: []string{"printer.corp.local"},
+		LocalAnswer: &filter.LocalAnswer{
+			A:   []net.IP{net.ParseIP("10.1.1.1")},
+			TTL: 120,
+		},
+	})
+
+	srv := NewServer(engine, 0)
+	resp := Process(srv.engine, buildTestQuery("printer.corp.local"), "192.168.1.1")
+	if resp == nil {
+		t.Fatal("process() returned nil response")
+	}
+
+	parser := dns.NewParser()
+	msg, err := parser.Parse(resp)
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+	if msg.GetRCode() != dns.RCodeNoError {
+		t.Errorf("RCODE = %d, want NOERROR", msg.GetRCode())
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("Answers = %d, want 1", len(msg.Answers))
+	}
+}
@@ -0,0 +1,59 @@
+package dns
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// TTLJitterWindowSeconds 是抖动种子的轮换周期: 同一 (client, qname, qtype) 在同一
+// 窗口内反复查询会得到完全相同的抖动 TTL, 避免短时间内重复请求暴露出"服务端每次
+// 都现算"的指纹, 窗口之间再重新派生一次, 类似 encrypted-dns-server 的
+// client_ttl_holdon 思路。
+const TTLJitterWindowSeconds = 300
+
+// TTLPolicy 描述 worker.handleAction 在把 Rule.RedirectTTL (或未来的 Rewrite TTL)
+// 写入响应前应做的抖动与夹取: JitterPercent<=0 表示不抖动, 只做 Min/MaxTTL 夹取
+type TTLPolicy struct {
+	JitterPercent int    // 抖动幅度, 单位 % (对应 dns.ttl_jitter_percent)
+	MinTTL        uint32 // 对应 dns.min_client_ttl, 0 表示不设下限
+	MaxTTL        uint32 // 对应 dns.max_client_ttl, 0 表示不设上限
+}
+
+// Apply 对 ttl 施加确定性抖动并夹取到 [MinTTL, MaxTTL]。抖动值由
+// (client, qname, qtype, 当前轮换窗口) 的哈希派生, 落在 [-JitterPercent%,
+// +JitterPercent%] 区间内。nowUnix 是当前 Unix 时间 (由调用方传入, 而不是在这里
+// 调用 time.Now(), 以便测试可复现)。
+func (p TTLPolicy) Apply(ttl uint32, client, qname string, qtype uint16, nowUnix int64) uint32 {
+	if p.JitterPercent > 0 {
+		window := nowUnix / TTLJitterWindowSeconds
+
+		h := sha256.New()
+		h.Write([]byte(client))
+		h.Write([]byte{0})
+		h.Write([]byte(qname))
+		var buf [10]byte
+		binary.BigEndian.PutUint16(buf[0:2], qtype)
+		binary.BigEndian.PutUint64(buf[2:10], uint64(window))
+		h.Write(buf[:])
+		seed := binary.BigEndian.Uint64(h.Sum(nil)[:8])
+
+		span := uint64(2*p.JitterPercent + 1)
+		offsetPercent := int64(seed%span) - int64(p.JitterPercent)
+
+		jittered := int64(ttl) + int64(ttl)*offsetPercent/100
+		if jittered < 0 {
+			jittered = 0
+		}
+		ttl = uint32(jittered)
+	}
+
+	if p.MinTTL > 0 && ttl < p.MinTTL {
+		// 同时覆盖"剩余 TTL 已接近 0"的场景: 返回一个小的非零 TTL 而不是 0,
+		// 避免客户端/中间缓存对同一条记录发起惊群式重查
+		ttl = p.MinTTL
+	}
+	if p.MaxTTL > 0 && ttl > p.MaxTTL {
+		ttl = p.MaxTTL
+	}
+	return ttl
+}
@@ -0,0 +1,48 @@
+package dns
+
+import "testing"
+
+func TestTTLPolicy_Apply_DeterministicWithinWindow(t *testing.T) {
+	policy := TTLPolicy{JitterPercent: 10}
+
+	windowStart := int64(7) * TTLJitterWindowSeconds
+	got1 := policy.Apply(300, "1.2.3.4", "example.com", TypeA, windowStart)
+	got2 := policy.Apply(300, "1.2.3.4", "example.com", TypeA, windowStart+TTLJitterWindowSeconds-1)
+	if got1 != got2 {
+		t.Errorf("Apply() not stable within the same window: %d vs %d", got1, got2)
+	}
+
+	if got1 < 270 || got1 > 330 {
+		t.Errorf("Apply() = %d, want within +-10%% of 300", got1)
+	}
+}
+
+func TestTTLPolicy_Apply_DiffersAcrossClientsOrWindows(t *testing.T) {
+	policy := TTLPolicy{JitterPercent: 20}
+
+	a := policy.Apply(600, "1.1.1.1", "example.com", TypeA, 0)
+	b := policy.Apply(600, "2.2.2.2", "example.com", TypeA, 0)
+	c := policy.Apply(600, "1.1.1.1", "example.com", TypeA, TTLJitterWindowSeconds*5)
+
+	if a == b && a == c {
+		t.Error("Apply() returned identical jitter for different clients and windows; expected some variation")
+	}
+}
+
+func TestTTLPolicy_Apply_ClampsToMinMax(t *testing.T) {
+	policy := TTLPolicy{MinTTL: 30, MaxTTL: 3600}
+
+	if got := policy.Apply(0, "1.2.3.4", "example.com", TypeA, 0); got != 30 {
+		t.Errorf("Apply(0) = %d, want MinTTL 30", got)
+	}
+	if got := policy.Apply(100000, "1.2.3.4", "example.com", TypeA, 0); got != 3600 {
+		t.Errorf("Apply(100000) = %d, want MaxTTL 3600", got)
+	}
+}
+
+func TestTTLPolicy_Apply_NoJitterPassesThroughWithinBounds(t *testing.T) {
+	policy := TTLPolicy{}
+	if got := policy.Apply(120, "1.2.3.4", "example.com", TypeA, 0); got != 120 {
+		t.Errorf("Apply(120) = %d, want unchanged 120", got)
+	}
+}
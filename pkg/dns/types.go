@@ -1,6 +1,9 @@
 package dns
 
-import "net"
+import (
+	"encoding/binary"
+	"net"
+)
 
 // DNS 查询类型常量
 const (
@@ -12,6 +15,11 @@ const (
 	TypeMX    uint16 = 15
 	TypeTXT   uint16 = 16
 	TypeAAAA  uint16 = 28
+	TypeSRV   uint16 = 33
+	TypeOPT   uint16 = 41
+	TypeSVCB  uint16 = 64
+	TypeHTTPS uint16 = 65
+	TypeCAA   uint16 = 257
 	TypeANY   uint16 = 255
 )
 
@@ -34,6 +42,12 @@ func TypeName(qtype uint16) string {
 		return "TXT"
 	case TypeAAAA:
 		return "AAAA"
+	case TypeSRV:
+		return "SRV"
+	case TypeSVCB:
+		return "SVCB"
+	case TypeHTTPS:
+		return "HTTPS"
 	case TypeANY:
 		return "ANY"
 	default:
@@ -81,6 +95,14 @@ type ResourceRecord struct {
 	TTL      uint32 // 生存时间
 	RDLength uint16 // 资源数据长度
 	RData    []byte // 资源数据
+
+	// RDataOffset 是 RData 在原始报文里的绝对起始偏移, 由 Parser.parseResourceRecord
+	// 填入; RDATA 内部出现的域名字段 (CNAME/NS/PTR/MX/SOA/SRV) 可能包含指回报文更早
+	// 位置的压缩指针, 必须相对整个报文而不是 RData 这个子切片来解析, 因此 DecodeRData
+	// 需要这个绝对偏移配合完整的 msg 重新进入 parseNameAt。不经由 Parser 构造的记录
+	// (如 filter/响应构建器自己拼出来的 ResourceRecord) 留空即可, 其 RDATA 里不应该
+	// 出现压缩指针。
+	RDataOffset int
 }
 
 // Message DNS 消息
@@ -90,140 +112,204 @@ type Message struct {
 	Answers    []ResourceRecord // 回答部分
 	Authority  []ResourceRecord // 授权部分
 	Additional []ResourceRecord // 附加部分
+	EDNS       *EDNS            // EDNS(0) OPT 伪记录 (RFC 6891), 无 OPT 时为 nil
 	RawData    []byte           // 原始数据
 }
 
-// IsQuery 判断是否为查询消息
-func (m *Message) IsQuery() bool {
-	return (m.Header.Flags & 0x8000) == 0
+// EDNSOption EDNS(0) 选项 (RFC 6891 OPT RDATA 中的一个 TLV)
+type EDNSOption struct {
+	Code uint16 // 选项代码, 如 COOKIE=10, NSID=3
+	Data []byte // 选项数据
 }
 
-// IsResponse 判断是否为响应消息
-func (m *Message) IsResponse() bool {
-	return (m.Header.Flags & 0x8000) != 0
-}
+// 常用 EDNS 选项代码
+const (
+	EDNSOptionNSID    uint16 = 3
+	EDNSOptionDAU     uint16 = 5
+	EDNSOptionDHU     uint16 = 6
+	EDNSOptionN3U     uint16 = 7
+	EDNSOptionCookie  uint16 = 10
+	EDNSOptionECS     uint16 = 8  // EDNS Client Subnet, RFC 7871
+	EDNSOptionPadding uint16 = 12 // RFC 7830
+)
 
-// GetRCode 获取响应码
-func (m *Message) GetRCode() uint16 {
-	return m.Header.Flags & 0x000F
+// ECS Family 字段取值 (与 AFI 一致)
+const (
+	ecsFamilyIPv4 uint16 = 1
+	ecsFamilyIPv6 uint16 = 2
+)
+
+// ECS 解析后的 EDNS Client Subnet 选项 (RFC 7871)
+type ECS struct {
+	SourcePrefixLen uint8  // 客户端声明的地址前缀长度
+	ScopePrefixLen  uint8  // 响应方声明的作用域前缀长度 (查询中恒为 0)
+	Address         net.IP // 补齐到完整长度后的子网地址
 }
 
-// GetOpCode 获取操作码
-func (m *Message) GetOpCode() uint16 {
-	return (m.Header.Flags >> 11) & 0x0F
+// EDNS 解析后的 EDNS(0) 信息, 来自查询的 OPT 伪记录
+type EDNS struct {
+	UDPPayloadSize uint16       // 客户端声明的 UDP 负载大小
+	ExtendedRCode  uint8        // 扩展 RCODE 高 8 位
+	Version        uint8        // EDNS 版本, 目前恒为 0
+	DO             bool         // DNSSEC OK 标志
+	Options        []EDNSOption // OPT RDATA 中的选项列表
 }
 
-// GetQueryName 获取第一个查询的域名
-func (m *Message) GetQueryName() string {
-	if len(m.Questions) > 0 {
-		return m.Questions[0].Name
+// GetOption 返回第一个匹配 code 的选项, 不存在时返回 nil
+func (e *EDNS) GetOption(code uint16) *EDNSOption {
+	if e == nil {
+		return nil
 	}
-	return ""
+	for i := range e.Options {
+		if e.Options[i].Code == code {
+			return &e.Options[i]
+		}
+	}
+	return nil
 }
 
-// GetQueryType 获取第一个查询的类型
-func (m *Message) GetQueryType() uint16 {
-	if len(m.Questions) > 0 {
-		return m.Questions[0].QType
+// Cookie 返回 OPT_COOKIE 选项中的原始字节, 不存在时返回 nil
+func (e *EDNS) Cookie() []byte {
+	opt := e.GetOption(EDNSOptionCookie)
+	if opt == nil {
+		return nil
 	}
-	return 0
+	return opt.Data
 }
 
-// BuildNXDomainResponse 构建 NXDOMAIN 响应
-func BuildNXDomainResponse(query *Message) []byte {
-	if query == nil || len(query.RawData) < 12 {
+// Padding 返回 OPT_PADDING 选项 (RFC 7830) 的原始填充字节, 不存在时返回 nil
+func (e *EDNS) Padding() []byte {
+	opt := e.GetOption(EDNSOptionPadding)
+	if opt == nil {
 		return nil
 	}
+	return opt.Data
+}
+
+// NewECSOption 构造一个 OPT_ECS 选项 (RFC 7871), 供调用方组装查询/响应的
+// EDNS.Options 后交给 Builder.writeOPT 编码
+func NewECSOption(sourcePrefixLen, scopePrefixLen uint8, addr net.IP) EDNSOption {
+	family := uint16(ecsFamilyIPv4)
+	raw := addr.To4()
+	if raw == nil {
+		family = ecsFamilyIPv6
+		raw = addr.To16()
+	}
 
-	response := make([]byte, 12)
-	copy(response, query.RawData[:12])
+	addrLen := (int(sourcePrefixLen) + 7) / 8
+	if addrLen > len(raw) {
+		addrLen = len(raw)
+	}
 
-	// 设置响应标志: QR=1, RCODE=3 (NXDOMAIN)
-	flags := uint16(0x8000) | (query.Header.Flags & 0x0100) | RCodeNXDomain
-	response[2] = byte(flags >> 8)
-	response[3] = byte(flags)
+	data := make([]byte, 4+addrLen)
+	binary.BigEndian.PutUint16(data[0:2], family)
+	data[2] = sourcePrefixLen
+	data[3] = scopePrefixLen
+	copy(data[4:], raw[:addrLen])
 
-	// 设置回答数为 0
-	response[6] = 0
-	response[7] = 0
+	return EDNSOption{Code: EDNSOptionECS, Data: data}
+}
 
-	// 添加问题部分
-	if len(query.RawData) > 12 {
-		response = append(response, query.RawData[12:]...)
-	}
+// NewCookieOption 构造一个 OPT_COOKIE 选项 (RFC 7873): clientCookie 恒为 8 字节,
+// serverCookie 为空时只发送 client cookie (查询场景), 非空时拼接为 client+server
+// (响应场景)
+func NewCookieOption(clientCookie, serverCookie []byte) EDNSOption {
+	data := make([]byte, 0, len(clientCookie)+len(serverCookie))
+	data = append(data, clientCookie...)
+	data = append(data, serverCookie...)
+	return EDNSOption{Code: EDNSOptionCookie, Data: data}
+}
 
-	return response
+// NewPaddingOption 构造一个长度为 n 字节、内容全为 0 的 OPT_PADDING 选项 (RFC 7830),
+// 用于把查询/响应填充到固定大小以抵御基于报文长度的流量分析
+func NewPaddingOption(n int) EDNSOption {
+	return EDNSOption{Code: EDNSOptionPadding, Data: make([]byte, n)}
 }
 
-// BuildAResponse 构建 A 记录响应
-func BuildAResponse(query *Message, ip net.IP, ttl uint32) []byte {
-	if query == nil || len(query.RawData) < 12 || ip == nil {
+// ECS 解析并返回 OPT_ECS 选项 (RFC 7871 §6), 没有该选项或格式不合法时返回 nil。
+// ADDRESS 字段按 SOURCE PREFIX-LENGTH 补齐到完整的 4 或 16 字节, 便于直接当作 net.IP 使用
+func (e *EDNS) ECS() *ECS {
+	opt := e.GetOption(EDNSOptionECS)
+	if opt == nil || len(opt.Data) < 4 {
+		return nil
+	}
+
+	family := binary.BigEndian.Uint16(opt.Data[0:2])
+	sourcePrefixLen := opt.Data[2]
+	scopePrefixLen := opt.Data[3]
+
+	var size int
+	switch family {
+	case ecsFamilyIPv4:
+		size = 4
+	case ecsFamilyIPv6:
+		size = 16
+	default:
 		return nil
 	}
 
-	ipv4 := ip.To4()
-	if ipv4 == nil {
+	raw := opt.Data[4:]
+	if len(raw) > size {
 		return nil
 	}
 
-	response := make([]byte, len(query.RawData))
-	copy(response, query.RawData)
-
-	// 设置响应标志: QR=1, AA=1, RD=query.RD, RCODE=0
-	flags := uint16(0x8400) | (query.Header.Flags & 0x0100)
-	response[2] = byte(flags >> 8)
-	response[3] = byte(flags)
-
-	// 设置回答数为 1
-	response[6] = 0
-	response[7] = 1
-
-	// 添加答案记录 (压缩指针指向问题中的域名)
-	// 格式: 压缩指针(2) + TYPE(2) + CLASS(2) + TTL(4) + RDLENGTH(2) + RDATA(4)
-	answer := []byte{
-		0xC0, 0x0C, // 压缩指针指向偏移 12 (问题部分的域名)
-		0x00, 0x01, // TYPE = A
-		0x00, 0x01, // CLASS = IN
-		byte(ttl >> 24), byte(ttl >> 16), byte(ttl >> 8), byte(ttl), // TTL
-		0x00, 0x04, // RDLENGTH = 4
-		ipv4[0], ipv4[1], ipv4[2], ipv4[3], // IP 地址
+	addr := make([]byte, size)
+	copy(addr, raw)
+
+	return &ECS{
+		SourcePrefixLen: sourcePrefixLen,
+		ScopePrefixLen:  scopePrefixLen,
+		Address:         net.IP(addr),
 	}
+}
 
-	return append(response, answer...)
+// IsQuery 判断是否为查询消息
+func (m *Message) IsQuery() bool {
+	return (m.Header.Flags & 0x8000) == 0
 }
 
-// BuildAAAAResponse 构建 AAAA 记录响应
-func BuildAAAAResponse(query *Message, ip net.IP, ttl uint32) []byte {
-	if query == nil || len(query.RawData) < 12 || ip == nil {
-		return nil
-	}
+// IsResponse 判断是否为响应消息
+func (m *Message) IsResponse() bool {
+	return (m.Header.Flags & 0x8000) != 0
+}
 
-	ipv6 := ip.To16()
-	if ipv6 == nil {
-		return nil
+// GetRCode 获取响应码
+func (m *Message) GetRCode() uint16 {
+	return m.Header.Flags & 0x000F
+}
+
+// GetOpCode 获取操作码
+func (m *Message) GetOpCode() uint16 {
+	return (m.Header.Flags >> 11) & 0x0F
+}
+
+// GetQueryName 获取第一个查询的域名
+func (m *Message) GetQueryName() string {
+	return m.GetQueryDomain()
+}
+
+// GetQueryDomain 获取第一个查询的域名
+func (m *Message) GetQueryDomain() string {
+	if len(m.Questions) > 0 {
+		return m.Questions[0].Name
 	}
+	return ""
+}
 
-	response := make([]byte, len(query.RawData))
-	copy(response, query.RawData)
-
-	// 设置响应标志
-	flags := uint16(0x8400) | (query.Header.Flags & 0x0100)
-	response[2] = byte(flags >> 8)
-	response[3] = byte(flags)
-
-	// 设置回答数为 1
-	response[6] = 0
-	response[7] = 1
-
-	// 添加答案记录
-	answer := []byte{
-		0xC0, 0x0C, // 压缩指针
-		0x00, 0x1C, // TYPE = AAAA
-		0x00, 0x01, // CLASS = IN
-		byte(ttl >> 24), byte(ttl >> 16), byte(ttl >> 8), byte(ttl), // TTL
-		0x00, 0x10, // RDLENGTH = 16
+// GetQueryType 获取第一个查询的类型
+func (m *Message) GetQueryType() uint16 {
+	if len(m.Questions) > 0 {
+		return m.Questions[0].QType
 	}
-	answer = append(answer, ipv6...)
+	return 0
+}
 
-	return append(response, answer...)
+// PTRAddress 把第一个查询域名解码为反向解析 (in-addr.arpa/ip6.arpa) 地址, 不是
+// 合法的反向解析域名时返回 (nil, false); 见 ParsePTRName
+func (m *Message) PTRAddress() (net.IP, bool) {
+	return ParsePTRName(m.GetQueryDomain())
 }
+
+// 响应构建逻辑 (BuildNXDomainResponse/BuildAResponse/BuildAAAAResponse 等) 见 response.go 中的
+// ResponseBuilder, 它支持 EDNS(0) 回显和按负载大小截断, 写入的域名不使用压缩指针
+// (带压缩的编码见 builder.go 的 Builder)。
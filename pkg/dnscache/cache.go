@@ -0,0 +1,314 @@
+// Package dnscache 在报文接入与 hybrid.Processor.Process 之间提供一层响应缓存
+//
+// 命中时直接返回一份预先序列化好、可以送进 TX 环的响应字节, 调用方只需要改写
+// 事务 ID 和 (如果有) EDNS 客户端 Cookie 这两处随查询变化的字段, 不必重新走一遍
+// Engine.CheckDomain + dns.Build*Response。按 (qname, qtype, qclass) 为键,
+// 使用对 qname 做 FNV 哈希分片的 LRU, 每个分片按字节数而不是条目数限额。
+//
+// ActionBlock/ActionAnswer 规则产出的条目 (参见 PinFor) 被标记为 pinned: 这类
+// 条目不会因为 TTL 倒数到 0 而过期失效, 只会因为显式 flush、规则重载时发现对应
+// 域名的规则发生了变化 (见 InvalidateRules), 或者所在分片超出字节容量时的 LRU
+// 淘汰 (与其它条目同等对待) 而被移除, 从而避免它们反复命中 Trie 查询。其它动作 (如 ActionForward 转发结果) 产出的条目仍按
+// RFC 1035 的 TTL 语义正常过期。
+package dnscache
+
+import (
+	"container/list"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xdp-dns/pkg/filter"
+	"xdp-dns/pkg/metrics"
+)
+
+const numShards = 32
+
+// Key 标识一条缓存记录, 对应一次查询的 (qname, qtype, qclass)
+type Key struct {
+	Name   string
+	QType  uint16
+	QClass uint16
+}
+
+// NewKey 由查询域名与类型构造缓存键, 域名按小写、去掉末尾根点规范化
+func NewKey(name string, qtype, qclass uint16) Key {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return Key{Name: name, QType: qtype, QClass: qclass}
+}
+
+// PinFor 判断给定动作产出的条目是否应该被固定 (不随 TTL 过期), 见包注释
+func PinFor(action filter.Action) bool {
+	return action == filter.ActionBlock || action == filter.ActionAnswer
+}
+
+// entry 是分片 LRU 中的一条缓存记录
+type entry struct {
+	key          Key
+	response     []byte // 规范化存放的响应字节 (ID 为原始构建时的值, TTL 为插入时的值)
+	ttlOffsets   []int  // response 中每个非 OPT 记录的 TTL 字段偏移, 供 Get 时原地改写
+	cookieOffset int    // OPT 选项里客户端 Cookie (前 8 字节) 的偏移, -1 表示没有
+	ttl          uint32 // 插入时的 TTL (负缓存已经折算为 SOA MINIMUM/配置上限)
+	createdAt    time.Time
+	pinned       bool
+	size         int // len(response), 用于按字节计的分片容量统计
+}
+
+// shard 是按 qname 的 FNV 哈希分桶后的一个独立 LRU
+type shard struct {
+	mu       sync.Mutex
+	items    map[Key]*list.Element
+	order    *list.List // 最近使用在前, 淘汰时从尾部开始
+	bytes    int
+	maxBytes int
+}
+
+// Cache 一个按字节容量分片限额的 DNS 响应缓存
+type Cache struct {
+	shards     [numShards]*shard
+	maxNegTTL  uint32 // 负缓存 TTL 上限 (RFC 2308), 秒
+	cacheBytes int64  // 当前占用字节数, 供 /metrics 以外的场景查询
+}
+
+// NewCache 创建一个总容量为 maxBytes 字节的缓存, 按 numShards 个分片均分;
+// maxNegativeTTL 是 RFC 2308 负缓存的 TTL 上限 (例如 5 分钟), <=0 时退化为 300s
+func NewCache(maxBytes int64, maxNegativeTTL time.Duration) *Cache {
+	if maxNegativeTTL <= 0 {
+		maxNegativeTTL = 300 * time.Second
+	}
+	perShard := int(maxBytes / numShards)
+	if perShard < 1 {
+		perShard = 1
+	}
+
+	c := &Cache{maxNegTTL: uint32(maxNegativeTTL.Seconds())}
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			items:    make(map[Key]*list.Element),
+			order:    list.New(),
+			maxBytes: perShard,
+		}
+	}
+	return c
+}
+
+func (c *Cache) shardFor(name string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return c.shards[h.Sum32()%numShards]
+}
+
+// Get 查找 key 对应的缓存条目, 命中时返回一份独立拷贝的响应字节, 其中事务 ID
+// 已经改写为 queryID, TTL 已经按存活时间原地递减, 且 (如果条目里带了 EDNS
+// Cookie 选项) 客户端 Cookie 部分已经替换为本次查询携带的 clientCookie。
+// 未命中或者已经过期 (非 pinned 条目 TTL 倒数到 0) 时返回 (nil, false)。
+func (c *Cache) Get(key Key, queryID uint16, clientCookie []byte) ([]byte, bool) {
+	sh := c.shardFor(key.Name)
+
+	sh.mu.Lock()
+	elem, ok := sh.items[key]
+	if !ok {
+		sh.mu.Unlock()
+		metrics.IncDNSCacheMiss()
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+
+	elapsed := uint32(time.Since(e.createdAt).Seconds())
+	if !e.pinned && elapsed >= e.ttl {
+		sh.order.Remove(elem)
+		delete(sh.items, key)
+		sh.bytes -= e.size
+		sh.mu.Unlock()
+		atomic.AddInt64(&c.cacheBytes, -int64(e.size))
+		metrics.IncDNSCacheMiss()
+		metrics.IncDNSCacheEviction("expired")
+		return nil, false
+	}
+
+	sh.order.MoveToFront(elem)
+	out := make([]byte, len(e.response))
+	copy(out, e.response)
+	remaining := e.ttl
+	if !e.pinned {
+		remaining = e.ttl - elapsed
+	}
+	for _, off := range e.ttlOffsets {
+		putUint32(out, off, remaining)
+	}
+	putUint16(out, 0, queryID)
+	if e.cookieOffset >= 0 && len(clientCookie) >= 8 {
+		copy(out[e.cookieOffset:e.cookieOffset+8], clientCookie[:8])
+	}
+
+	sh.mu.Unlock()
+	metrics.IncDNSCacheHit()
+	return out, true
+}
+
+// Put 缓存一条肯定应答, ttl 是响应中实际写入的 TTL, pinned 为 true 时该条目
+// 不会因为 TTL 倒数到 0 而过期 (见 PinFor)
+func (c *Cache) Put(key Key, response []byte, ttl uint32, pinned bool) {
+	c.insert(key, response, ttl, pinned)
+}
+
+// PutNegative 缓存一条否定应答 (NXDOMAIN/NODATA), 按 RFC 2308 用 soaMinimum
+// (从上游响应 Authority 部分的 SOA 记录 MINIMUM 字段取得, 取不到时传 0) 与配置的
+// 负缓存上限取较小值作为 TTL; pinned 为 true 时同样不随 TTL 过期 (如 ActionBlock
+// 规则产出的 NXDOMAIN)。
+func (c *Cache) PutNegative(key Key, response []byte, soaMinimum uint32, pinned bool) {
+	ttl := c.maxNegTTL
+	if soaMinimum > 0 && soaMinimum < ttl {
+		ttl = soaMinimum
+	}
+	c.insert(key, response, ttl, pinned)
+}
+
+func (c *Cache) insert(key Key, response []byte, ttl uint32, pinned bool) {
+	if len(response) < 12 {
+		return
+	}
+	sh := c.shardFor(key.Name)
+	ttlOffsets, cookieOffset := scanRecordOffsets(response)
+
+	e := &entry{
+		key:          key,
+		response:     append([]byte(nil), response...),
+		ttlOffsets:   ttlOffsets,
+		cookieOffset: cookieOffset,
+		ttl:          ttl,
+		createdAt:    time.Now(),
+		pinned:       pinned,
+		size:         len(response),
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if old, exists := sh.items[key]; exists {
+		oldEntry := old.Value.(*entry)
+		sh.bytes -= oldEntry.size
+		sh.order.Remove(old)
+		delete(sh.items, key)
+		atomic.AddInt64(&c.cacheBytes, -int64(oldEntry.size))
+	}
+
+	elem := sh.order.PushFront(e)
+	sh.items[key] = elem
+	sh.bytes += e.size
+	atomic.AddInt64(&c.cacheBytes, int64(e.size))
+
+	for sh.bytes > sh.maxBytes && sh.order.Len() > 1 {
+		c.evictOldest(sh)
+	}
+}
+
+// evictOldest 淘汰分片 LRU 链表尾部 (最久未使用) 的一条记录, 调用方需持有 sh.mu
+func (c *Cache) evictOldest(sh *shard) {
+	oldest := sh.order.Back()
+	if oldest == nil {
+		return
+	}
+	e := oldest.Value.(*entry)
+	sh.order.Remove(oldest)
+	delete(sh.items, e.key)
+	sh.bytes -= e.size
+	atomic.AddInt64(&c.cacheBytes, -int64(e.size))
+	metrics.IncDNSCacheEviction("capacity")
+}
+
+// InvalidateSuffix 删除所有域名等于 suffix 或者是 suffix 子域的缓存条目,
+// 返回删除的条目数; suffix 为空时清空整个缓存。供管理端按域名后缀整体失效使用,
+// 也是 InvalidateRules 失效单条规则域名的基础操作。
+func (c *Cache) InvalidateSuffix(suffix string) int {
+	suffix = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(suffix), "."))
+	removed := 0
+	for _, sh := range c.shards {
+		sh.mu.Lock()
+		for key, elem := range sh.items {
+			if suffix != "" && key.Name != suffix && !strings.HasSuffix(key.Name, "."+suffix) {
+				continue
+			}
+			e := elem.Value.(*entry)
+			sh.order.Remove(elem)
+			delete(sh.items, key)
+			sh.bytes -= e.size
+			atomic.AddInt64(&c.cacheBytes, -int64(e.size))
+			removed++
+		}
+		sh.mu.Unlock()
+	}
+	if removed > 0 {
+		metrics.IncDNSCacheEviction("invalidated")
+	}
+	return removed
+}
+
+// InvalidateRules 在一次规则热重载之后, 失效所有域名归属于 changed 里任一规则
+// 的缓存条目 (changed 通常是 filter.Snapshot.Diff 结果里 Added/Modified/Removed
+// 对应的规则, 由调用方从重载前后的 Snapshot 里按 ID 取出), 返回删除的条目总数。
+// 通配符域名 ("*.example.com") 按去掉 "*." 前缀的后缀失效, 覆盖所有子域。
+func (c *Cache) InvalidateRules(changed []filter.Rule) int {
+	removed := 0
+	for _, rule := range changed {
+		for _, domain := range rule.Domains {
+			domain = strings.TrimPrefix(domain, "*.")
+			removed += c.InvalidateSuffix(domain)
+		}
+	}
+	return removed
+}
+
+// RegisterAdminHandlers 把按后缀 flush 的管理端点挂载到 mux 上, 供内部运维工具
+// 调用; 与 pkg/metrics.Exporter 的 /stats 端点同属"简单 HTTP 管理接口"这一套风格,
+// 不引入额外的 RPC 框架。POST /dnscache/flush?suffix=example.com, suffix 省略时
+// 清空整个缓存。
+func (c *Cache) RegisterAdminHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/dnscache/flush", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		suffix := r.URL.Query().Get("suffix")
+		removed := c.InvalidateSuffix(suffix)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"removed":` + itoa(removed) + `}`))
+	})
+}
+
+func itoa(n int) string {
+	if n == 0 {
+		return "0"
+	}
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	var buf [20]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = byte('0' + n%10)
+		n /= 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
+func putUint16(b []byte, off int, v uint16) {
+	b[off] = byte(v >> 8)
+	b[off+1] = byte(v)
+}
+
+func putUint32(b []byte, off int, v uint32) {
+	b[off] = byte(v >> 24)
+	b[off+1] = byte(v >> 16)
+	b[off+2] = byte(v >> 8)
+	b[off+3] = byte(v)
+}
@@ -0,0 +1,134 @@
+package dnscache
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/filter"
+)
+
+func buildTestResponse(t *testing.T, ttl uint32) []byte {
+	t.Helper()
+	query := &dns.Message{
+		Header:    dns.Header{ID: 0xAAAA},
+		Questions: []dns.Question{{Name: "example.com", QType: dns.TypeA, QClass: dns.ClassIN}},
+	}
+	resp := dns.BuildAResponse(query, []byte{1, 2, 3, 4}, ttl)
+	if resp == nil {
+		t.Fatal("BuildAResponse() returned nil")
+	}
+	return resp
+}
+
+func TestCache_PutGet_RewritesIDAndDecrementsTTL(t *testing.T) {
+	c := NewCache(1<<20, 0)
+	key := NewKey("example.com", dns.TypeA, dns.ClassIN)
+	c.Put(key, buildTestResponse(t, 100), 100, false)
+
+	out, ok := c.Get(key, 0x1234, nil)
+	if !ok {
+		t.Fatal("Get() ok = false, want true")
+	}
+	if got := binary.BigEndian.Uint16(out[0:2]); got != 0x1234 {
+		t.Fatalf("response ID = %#x, want 0x1234", got)
+	}
+
+	msg, err := dns.NewParser().Parse(out)
+	if err != nil {
+		t.Fatalf("Parse(cached response) error = %v", err)
+	}
+	if len(msg.Answers) != 1 || msg.Answers[0].TTL > 100 {
+		t.Fatalf("Answers = %+v, want a single answer with TTL <= 100", msg.Answers)
+	}
+}
+
+func TestCache_Get_Miss(t *testing.T) {
+	c := NewCache(1<<20, 0)
+	if _, ok := c.Get(NewKey("absent.example.com", dns.TypeA, dns.ClassIN), 1, nil); ok {
+		t.Fatal("Get() on an absent key should miss")
+	}
+}
+
+func TestCache_PinnedEntry_SurvivesTTLExpiry(t *testing.T) {
+	c := NewCache(1<<20, 0)
+	key := NewKey("blocked.example.com", dns.TypeA, dns.ClassIN)
+	c.Put(key, buildTestResponse(t, 1), 1, true)
+
+	// 固定条目即便 TTL 是 1 秒也不应该靠经过的时间过期失效
+	time.Sleep(1200 * time.Millisecond)
+
+	if _, ok := c.Get(key, 1, nil); !ok {
+		t.Fatal("Get() on a pinned entry should not expire")
+	}
+}
+
+func TestCache_NonPinnedEntry_ExpiresAfterTTL(t *testing.T) {
+	c := NewCache(1<<20, 0)
+	key := NewKey("ttl.example.com", dns.TypeA, dns.ClassIN)
+	c.Put(key, buildTestResponse(t, 1), 1, false)
+
+	time.Sleep(1200 * time.Millisecond)
+
+	if _, ok := c.Get(key, 1, nil); ok {
+		t.Fatal("Get() on a non-pinned expired entry should miss")
+	}
+}
+
+func TestCache_PutNegative_BoundsToConfiguredMax(t *testing.T) {
+	c := NewCache(1<<20, 5*time.Second)
+	key := NewKey("nx.example.com", dns.TypeA, dns.ClassIN)
+	query := &dns.Message{Header: dns.Header{ID: 1}, Questions: []dns.Question{{Name: "nx.example.com", QType: dns.TypeA, QClass: dns.ClassIN}}}
+	c.PutNegative(key, dns.BuildNXDomainResponse(query), 3600, false)
+
+	sh := c.shardFor(key.Name)
+	sh.mu.Lock()
+	elem := sh.items[key]
+	ttl := elem.Value.(*entry).ttl
+	sh.mu.Unlock()
+
+	if ttl != 5 {
+		t.Fatalf("negative TTL = %d, want 5 (bounded by configured max)", ttl)
+	}
+}
+
+func TestCache_InvalidateSuffix(t *testing.T) {
+	c := NewCache(1<<20, 0)
+	c.Put(NewKey("a.example.com", dns.TypeA, dns.ClassIN), buildTestResponse(t, 60), 60, true)
+	c.Put(NewKey("other.test", dns.TypeA, dns.ClassIN), buildTestResponse(t, 60), 60, true)
+
+	if removed := c.InvalidateSuffix("example.com"); removed != 1 {
+		t.Fatalf("InvalidateSuffix() removed = %d, want 1", removed)
+	}
+	if _, ok := c.Get(NewKey("a.example.com", dns.TypeA, dns.ClassIN), 1, nil); ok {
+		t.Fatal("invalidated entry should no longer be cached")
+	}
+	if _, ok := c.Get(NewKey("other.test", dns.TypeA, dns.ClassIN), 1, nil); !ok {
+		t.Fatal("unrelated entry should survive InvalidateSuffix")
+	}
+}
+
+func TestCache_InvalidateRules_WildcardDomain(t *testing.T) {
+	c := NewCache(1<<20, 0)
+	c.Put(NewKey("sub.ads.example.com", dns.TypeA, dns.ClassIN), buildTestResponse(t, 60), 60, true)
+
+	removed := c.InvalidateRules([]filter.Rule{{ID: "ads", Domains: []string{"*.ads.example.com"}}})
+	if removed != 1 {
+		t.Fatalf("InvalidateRules() removed = %d, want 1", removed)
+	}
+}
+
+func TestPinFor(t *testing.T) {
+	cases := map[filter.Action]bool{
+		filter.ActionBlock:   true,
+		filter.ActionAnswer:  true,
+		filter.ActionAllow:   false,
+		filter.ActionForward: false,
+	}
+	for action, want := range cases {
+		if got := PinFor(action); got != want {
+			t.Errorf("PinFor(%v) = %v, want %v", action, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package dnscache
+
+import "encoding/binary"
+
+// dnsTypeOPT/ednsOptionCookie 避免直接依赖 pkg/dns 里的常量集合, 只复制本文件实际用得到的
+// 两个: OPT 伪记录的 TTL 字段另作他用 (扩展 RCODE/版本/flags, RFC 6891 §6.1.3),
+// 不是真正的生存时间, 缓存寻址 TTL 字段时需要跳过它。
+const (
+	dnsTypeOPT       uint16 = 41
+	ednsOptionCookie uint16 = 10
+)
+
+// scanRecordOffsets 走一遍报文的 Answer/Authority/Additional 三个部分, 返回每条
+// 非 OPT 记录 TTL 字段的字节偏移 (Get 时原地递减改写), 以及 OPT 记录里 Cookie 选项
+// 客户端部分 (前 8 字节) 的偏移 (没有则为 -1)。只做跳跃式扫描, 不解码记录内容,
+// 因此不依赖 pkg/dns.Parser 对外暴露偏移信息 (它目前只返回解码后的结构体) ——
+// 与 pkg/tcpserver 复制 2 字节长度帧读写而不是导入 pkg/dns/tcp 的未导出实现是
+// 同一种权衡: 两边各自维护一份很小的 wire-format helper, 避免引入包间耦合。
+func scanRecordOffsets(resp []byte) (ttlOffsets []int, cookieOffset int) {
+	cookieOffset = -1
+	if len(resp) < 12 {
+		return nil, -1
+	}
+
+	qdcount := binary.BigEndian.Uint16(resp[4:6])
+	ancount := binary.BigEndian.Uint16(resp[6:8])
+	nscount := binary.BigEndian.Uint16(resp[8:10])
+	arcount := binary.BigEndian.Uint16(resp[10:12])
+
+	off := 12
+	for i := 0; i < int(qdcount); i++ {
+		nameEnd, ok := skipName(resp, off)
+		if !ok || nameEnd+4 > len(resp) {
+			return ttlOffsets, cookieOffset
+		}
+		off = nameEnd + 4 // QTYPE(2) + QCLASS(2)
+	}
+
+	total := int(ancount) + int(nscount) + int(arcount)
+	for i := 0; i < total; i++ {
+		nameEnd, ok := skipName(resp, off)
+		if !ok || nameEnd+10 > len(resp) {
+			return ttlOffsets, cookieOffset
+		}
+
+		rtype := binary.BigEndian.Uint16(resp[nameEnd : nameEnd+2])
+		ttlOff := nameEnd + 4
+		rdlen := int(binary.BigEndian.Uint16(resp[nameEnd+8 : nameEnd+10]))
+		rdataOff := nameEnd + 10
+		if rdataOff+rdlen > len(resp) {
+			return ttlOffsets, cookieOffset
+		}
+
+		if rtype == dnsTypeOPT {
+			cookieOffset = findCookieOffset(resp[rdataOff:rdataOff+rdlen], rdataOff)
+		} else {
+			ttlOffsets = append(ttlOffsets, ttlOff)
+		}
+
+		off = rdataOff + rdlen
+	}
+
+	return ttlOffsets, cookieOffset
+}
+
+// findCookieOffset 在 OPT 记录的 RDATA (一串 TLV 选项) 里找 COOKIE 选项 (RFC 7873),
+// 返回其客户端部分 (前 8 字节) 在整个报文里的绝对偏移, 没有则返回 -1;
+// base 是 rdata 在报文里的起始偏移。
+func findCookieOffset(rdata []byte, base int) int {
+	pos := 0
+	for pos+4 <= len(rdata) {
+		code := binary.BigEndian.Uint16(rdata[pos : pos+2])
+		optLen := int(binary.BigEndian.Uint16(rdata[pos+2 : pos+4]))
+		if pos+4+optLen > len(rdata) {
+			return -1
+		}
+		if code == ednsOptionCookie && optLen >= 8 {
+			return base + pos + 4
+		}
+		pos += 4 + optLen
+	}
+	return -1
+}
+
+// skipName 跳过报文里从 off 开始的一个域名 (处理压缩指针), 返回该记录里紧跟在
+// 域名之后的偏移; 只用于定位后续固定长度字段, 不返回域名本身也不检测指针环
+// (本包只扫描我们自己刚构建或者刚从上游收到、结构合法的响应, 不是抗攻击的
+// 通用解析器, 真正的解析/指针环检测见 pkg/dns.Parser.parseName)。
+func skipName(resp []byte, off int) (int, bool) {
+	for {
+		if off >= len(resp) {
+			return 0, false
+		}
+		length := int(resp[off])
+		if length == 0 {
+			return off + 1, true
+		}
+		if length&0xC0 == 0xC0 {
+			if off+1 >= len(resp) {
+				return 0, false
+			}
+			return off + 2, true
+		}
+		off += 1 + length
+	}
+}
@@ -0,0 +1,56 @@
+package dnstap
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xdp-dns/pkg/filter"
+)
+
+func TestFileSink_WriteEventAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnstap.log")
+
+	sink, err := NewFileSink(path, 10) // 很小的阈值, 确保第二条事件触发滚动
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	engineSink := NewEngineSink(sink)
+	engineSink.OnDecision(filter.Decision{SrcIP: "192.168.1.1", Domain: "ads.example.com", Action: filter.ActionBlock, RuleID: "block-ads"})
+	engineSink.OnDecision(filter.Decision{SrcIP: "2001:db8::1", Domain: "good.example.com", Action: filter.ActionAllow})
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("expected rotation to produce at least 2 files, got %d", len(entries))
+	}
+}
+
+func TestEncodeFrame_RoundTrip(t *testing.T) {
+	event := Event{Type: MessageTypeClientQuery, Domain: "example.com", Action: "block"}
+
+	frame, err := encodeFrame(event)
+	if err != nil {
+		t.Fatalf("encodeFrame() error = %v", err)
+	}
+
+	length := binary.BigEndian.Uint32(frame[0:4])
+	if int(length) != len(frame)-4 {
+		t.Fatalf("frame length prefix = %d, want %d", length, len(frame)-4)
+	}
+
+	var decoded Event
+	if err := json.Unmarshal(frame[4:], &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if decoded.Domain != event.Domain || decoded.Action != event.Action {
+		t.Errorf("decoded event = %+v, want %+v", decoded, event)
+	}
+}
@@ -0,0 +1,248 @@
+package fakedns
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/metrics"
+)
+
+// Config 描述 fakedns 子系统的地址池和映射表行为
+type Config struct {
+	IPv4Pools   []string      // IPv4 地址池 CIDR 列表, 如 198.18.0.0/15
+	IPv6Pools   []string      // IPv6 地址池 CIDR 列表, 如 fc00::/18
+	PoolSize    int           // domain<->ip 映射表最大容量, 超出后按 LRU 淘汰最久未用的映射
+	TTL         time.Duration // 每条映射的存活时间, 超过后 Allocate 视为过期并可能重新分配
+	GracePeriod time.Duration // 映射过期后, 还要再保留多久才允许这个 IP 被分配给其它域名复用
+}
+
+const (
+	defaultPoolSize    = 65536
+	defaultTTL         = time.Hour
+	defaultGracePeriod = time.Minute
+)
+
+// mapping 是一条 domain<->ip 映射。LRU 容量溢出时会把它从 byDomain/lru 中摘除
+// (live=false), 但仍在 byIP 里保留到 GracePeriod 结束, 这样分配探测到这个 IP
+// 时才知道该等多久才能把它判给别的域名。
+type mapping struct {
+	domain    string
+	ip        string
+	family    uint16
+	expiresAt time.Time
+	live      bool
+	elem      *list.Element
+}
+
+// Manager 是 fakedns 子系统: 管理 IPv4/IPv6 地址池、domain<->ip 双向映射的 LRU,
+// 以及过期映射在 GracePeriod 之后才允许被复用的 IP 回收策略
+type Manager struct {
+	mu sync.Mutex
+
+	v4Pools []*ipPool
+	v6Pools []*ipPool
+
+	ttl        time.Duration
+	grace      time.Duration
+	maxEntries int
+	lru        *list.List
+	byDomain   map[string]*mapping
+	byIP       map[string]*mapping
+}
+
+// NewManager 创建 fakedns 子系统, 至少需要配置一个 IPv4 或 IPv6 地址池
+func NewManager(cfg Config) (*Manager, error) {
+	m := &Manager{
+		ttl:        cfg.TTL,
+		grace:      cfg.GracePeriod,
+		maxEntries: cfg.PoolSize,
+		lru:        list.New(),
+		byDomain:   make(map[string]*mapping),
+		byIP:       make(map[string]*mapping),
+	}
+	if m.ttl <= 0 {
+		m.ttl = defaultTTL
+	}
+	if m.grace <= 0 {
+		m.grace = defaultGracePeriod
+	}
+	if m.maxEntries <= 0 {
+		m.maxEntries = defaultPoolSize
+	}
+
+	for _, cidr := range cfg.IPv4Pools {
+		pool, err := newIPPool(cidr)
+		if err != nil {
+			return nil, err
+		}
+		m.v4Pools = append(m.v4Pools, pool)
+	}
+	for _, cidr := range cfg.IPv6Pools {
+		pool, err := newIPPool(cidr)
+		if err != nil {
+			return nil, err
+		}
+		m.v6Pools = append(m.v6Pools, pool)
+	}
+	if len(m.v4Pools) == 0 && len(m.v6Pools) == 0 {
+		return nil, fmt.Errorf("fakedns: at least one of ipv4_pools/ipv6_pools must be configured")
+	}
+
+	return m, nil
+}
+
+// Allocate 返回 domain 在 qtype (dns.TypeA 或 dns.TypeAAAA) 协议族下当前生效的 IP:
+// 如果已有未过期的映射就直接复用并续期, 否则从对应协议族的地址池里分配一个新地址
+func (m *Manager) Allocate(domain string, qtype uint16) (net.IP, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	if e, ok := m.byDomain[domain]; ok && e.family == qtype && now.Before(e.expiresAt) {
+		e.expiresAt = now.Add(m.ttl)
+		m.lru.MoveToFront(e.elem)
+		return net.ParseIP(e.ip), nil
+	}
+
+	pools := m.v4Pools
+	if qtype == dns.TypeAAAA {
+		pools = m.v6Pools
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("fakedns: no address pool configured for query type %s", dns.TypeName(qtype))
+	}
+
+	ip, err := m.allocateFromPoolsLocked(pools, now)
+	if err != nil {
+		return nil, err
+	}
+
+	m.insertLocked(domain, ip, qtype, now)
+	metrics.IncFakeDNSAllocation()
+	m.reportUtilizationLocked()
+	return ip, nil
+}
+
+// allocateFromPoolsLocked 在给定的一组地址池里寻找一个空闲 (或已过保留期可回收的)
+// 地址, 调用方需持有 m.mu
+func (m *Manager) allocateFromPoolsLocked(pools []*ipPool, now time.Time) (net.IP, error) {
+	for _, pool := range pools {
+		attempts := pool.capacity
+		for i := uint64(0); i < attempts; i++ {
+			ip := pool.next()
+			key := ip.String()
+
+			existing, occupied := m.byIP[key]
+			if !occupied {
+				return ip, nil
+			}
+
+			metrics.IncFakeDNSCollision()
+			if existing.live || now.Sub(existing.expiresAt) < m.grace {
+				continue // 仍在使用或保留期未过, 继续探测下一个地址
+			}
+
+			// 过期且已超过保留期: 可以回收复用, 先摘掉旧的反查映射
+			delete(m.byIP, key)
+			return ip, nil
+		}
+	}
+	return nil, ErrPoolExhausted
+}
+
+// insertLocked 建立一条新的 domain<->ip 映射并推进 LRU, 必要时淘汰最久未用的映射
+func (m *Manager) insertLocked(domain string, ip net.IP, qtype uint16, now time.Time) {
+	if old, ok := m.byDomain[domain]; ok {
+		m.removeLiveLocked(old)
+	}
+
+	e := &mapping{domain: domain, ip: ip.String(), family: qtype, expiresAt: now.Add(m.ttl), live: true}
+	e.elem = m.lru.PushFront(e)
+	m.byDomain[domain] = e
+	m.byIP[e.ip] = e
+
+	for len(m.byDomain) > m.maxEntries {
+		oldest := m.lru.Back()
+		if oldest == nil {
+			break
+		}
+		m.evictLRULocked(oldest.Value.(*mapping))
+	}
+}
+
+// removeLiveLocked 把一条映射从 LRU 和 byDomain 中摘除, 并从 byIP 中彻底删除
+// (调用场景是同一个域名重新分配, 旧映射不需要进入保留期)
+func (m *Manager) removeLiveLocked(e *mapping) {
+	m.lru.Remove(e.elem)
+	delete(m.byDomain, e.domain)
+	delete(m.byIP, e.ip)
+}
+
+// evictLRULocked 因容量超限淘汰一条映射: 从 LRU/byDomain 里摘除, 但 byIP 里的
+// 记录保留为"已下线" (live=false), 直到 GracePeriod 结束才允许这个 IP 被复用
+func (m *Manager) evictLRULocked(e *mapping) {
+	m.lru.Remove(e.elem)
+	delete(m.byDomain, e.domain)
+	e.live = false
+}
+
+// Lookup 是反查 API: 根据分配出去的 IP 还原原始域名, 只有仍然存活的映射才会命中
+func (m *Manager) Lookup(ip net.IP) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.byIP[ip.String()]
+	if !ok || !e.live {
+		return "", false
+	}
+	return e.domain, true
+}
+
+// Sweep 扫描所有存活映射, 把已经过期的映射从 LRU/byDomain 中摘除 (进入保留期),
+// 用于在没有新查询触发 Allocate 的情况下也能及时释放地址
+func (m *Manager) Sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var expired []*mapping
+	for e := m.lru.Back(); e != nil; e = e.Prev() {
+		entry := e.Value.(*mapping)
+		if now.Before(entry.expiresAt) {
+			break // lru 按最近使用排序, 一旦遇到未过期的就可以停止
+		}
+		expired = append(expired, entry)
+	}
+	for _, e := range expired {
+		m.evictLRULocked(e)
+	}
+	if len(expired) > 0 {
+		m.reportUtilizationLocked()
+	}
+}
+
+// Run 周期性地调用 Sweep, 直到 ctx 结束
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Sweep()
+		}
+	}
+}
+
+// reportUtilizationLocked 把当前映射表占用率上报给 Prometheus, 调用方需持有 m.mu
+func (m *Manager) reportUtilizationLocked() {
+	metrics.SetFakeDNSPoolUtilization(float64(len(m.byDomain)) / float64(m.maxEntries))
+}
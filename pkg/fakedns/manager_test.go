@@ -0,0 +1,181 @@
+package fakedns
+
+import (
+	"testing"
+	"time"
+
+	"xdp-dns/pkg/dns"
+)
+
+func TestManager_AllocateReusesSameDomain(t *testing.T) {
+	m, err := NewManager(Config{IPv4Pools: []string{"198.18.0.0/30"}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	ip1, err := m.Allocate("example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	ip2, err := m.Allocate("example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if ip1.String() != ip2.String() {
+		t.Fatalf("same domain should reuse the same IP, got %s then %s", ip1, ip2)
+	}
+}
+
+func TestManager_AllocateAssignsDistinctIPsToDistinctDomains(t *testing.T) {
+	m, err := NewManager(Config{IPv4Pools: []string{"198.18.0.0/24"}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	ip1, err := m.Allocate("a.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	ip2, err := m.Allocate("b.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if ip1.String() == ip2.String() {
+		t.Fatalf("distinct domains should get distinct IPs, both got %s", ip1)
+	}
+}
+
+func TestManager_LookupReverseResolves(t *testing.T) {
+	m, err := NewManager(Config{IPv4Pools: []string{"198.18.0.0/24"}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	ip, err := m.Allocate("example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	domain, ok := m.Lookup(ip)
+	if !ok || domain != "example.com" {
+		t.Fatalf("Lookup(%s) = %q, %v; want example.com, true", ip, domain, ok)
+	}
+}
+
+func TestManager_IPv4AndIPv6PoolsAreSeparate(t *testing.T) {
+	m, err := NewManager(Config{
+		IPv4Pools: []string{"198.18.0.0/24"},
+		IPv6Pools: []string{"fc00::/120"},
+	})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	ipv4, err := m.Allocate("example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate(A) error = %v", err)
+	}
+	if ipv4.To4() == nil {
+		t.Fatalf("Allocate(A) returned non-IPv4 address %s", ipv4)
+	}
+
+	ipv6, err := m.Allocate("example.com", dns.TypeAAAA)
+	if err != nil {
+		t.Fatalf("Allocate(AAAA) error = %v", err)
+	}
+	if ipv6.To4() != nil {
+		t.Fatalf("Allocate(AAAA) returned non-IPv6 address %s", ipv6)
+	}
+}
+
+func TestManager_LRUEvictsOldestOnceOverCapacity(t *testing.T) {
+	m, err := NewManager(Config{IPv4Pools: []string{"198.18.0.0/24"}, PoolSize: 2})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	if _, err := m.Allocate("a.example.com", dns.TypeA); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if _, err := m.Allocate("b.example.com", dns.TypeA); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if _, err := m.Allocate("c.example.com", dns.TypeA); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	if _, ok := m.byDomain["a.example.com"]; ok {
+		t.Fatal("oldest domain mapping should have been evicted once PoolSize was exceeded")
+	}
+	if _, ok := m.byDomain["c.example.com"]; !ok {
+		t.Fatal("most recently allocated domain mapping should still be present")
+	}
+}
+
+func TestManager_EvictedIPIsNotReusedBeforeGracePeriod(t *testing.T) {
+	m, err := NewManager(Config{IPv4Pools: []string{"198.18.0.0/30"}, PoolSize: 1, GracePeriod: time.Minute})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	ip1, err := m.Allocate("a.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	// Force the mapping's IP slot to be evicted from byDomain/lru (as the LRU
+	// capacity check would on the next allocation), but keep a fresh expiry so
+	// it's still within the grace period.
+	m.mu.Lock()
+	e := m.byIP[ip1.String()]
+	m.evictLRULocked(e)
+	e.expiresAt = time.Now()
+	m.mu.Unlock()
+
+	ip2, err := m.Allocate("b.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if ip2.String() == ip1.String() {
+		t.Fatalf("evicted IP %s should not be reused before the grace period elapses", ip1)
+	}
+
+	// Push the eviction far enough into the past that the grace period has elapsed.
+	m.mu.Lock()
+	e.expiresAt = time.Now().Add(-2 * time.Minute)
+	m.mu.Unlock()
+
+	ip3, err := m.Allocate("c.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if ip3.String() != ip1.String() {
+		t.Fatalf("Allocate() = %s, want the reclaimed IP %s once the grace period has elapsed", ip3, ip1)
+	}
+}
+
+func TestManager_AllocateReturnsErrPoolExhausted(t *testing.T) {
+	m, err := NewManager(Config{IPv4Pools: []string{"198.18.0.0/30"}, PoolSize: 10, GracePeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	// /30 yields a capacity-2 pool (network+broadcast reserved); both slots are
+	// handed out and stay live, so a third allocation has nowhere to go.
+	if _, err := m.Allocate("a.example.com", dns.TypeA); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if _, err := m.Allocate("b.example.com", dns.TypeA); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	if _, err := m.Allocate("c.example.com", dns.TypeA); err != ErrPoolExhausted {
+		t.Fatalf("Allocate() error = %v, want ErrPoolExhausted", err)
+	}
+}
+
+func TestManager_NewManagerRequiresAtLeastOnePool(t *testing.T) {
+	if _, err := NewManager(Config{}); err == nil {
+		t.Fatal("NewManager() with no pools configured should return an error")
+	}
+}
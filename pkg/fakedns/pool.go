@@ -0,0 +1,91 @@
+// Package fakedns 实现类似 Xray Fake DNS 的即时 A/AAAA 地址分配: 为匹配
+// fakedns 规则的查询从一个私有地址池中分配 (或复用) 一个 IP, 记录
+// domain<->ip 的双向映射, 供下游的路由/重定向层通过 Lookup(ip) 还原原始域名。
+package fakedns
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+)
+
+// maxPoolCapacity 是单个地址池纳入游标轮转的地址数量上限, 避免超大 IPv6 前缀
+// (如 fc00::/18) 把游标空间撑到不现实的规模; 池容量始终在真实 CIDR 容量和此值之间取较小者
+const maxPoolCapacity = 1 << 20
+
+// ErrPoolExhausted 表示地址池内没有可分配或可回收的地址
+var ErrPoolExhausted = errors.New("fakedns: address pool exhausted")
+
+// ipPool 是单个 CIDR 内的地址分配器: 按顺序游标分配地址, 调用方负责判断候选
+// 地址是否已被占用 (占用时游标会继续向后探测, 由 Manager 统计为一次碰撞)
+type ipPool struct {
+	mu       sync.Mutex
+	cidr     string
+	base     *big.Int // 池内第一个可分配地址对应的整数值 (已跳过网络地址本身)
+	capacity uint64
+	cursor   uint64
+	is4      bool
+}
+
+func newIPPool(cidr string) (*ipPool, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("fakedns: invalid pool cidr %q: %w", cidr, err)
+	}
+
+	is4 := ip.To4() != nil
+	ones, bits := ipnet.Mask.Size()
+	hostBits := uint(bits - ones)
+
+	var capacity uint64
+	if hostBits >= 64 {
+		capacity = maxPoolCapacity
+	} else {
+		capacity = uint64(1) << hostBits
+		if capacity > maxPoolCapacity {
+			capacity = maxPoolCapacity
+		}
+	}
+	if is4 && capacity > 2 {
+		capacity -= 2 // 跳过网络地址和广播地址
+	} else if hostBits < 64 && capacity == uint64(1)<<hostBits {
+		capacity-- // 跳过网络地址本身, 使最后一个偏移仍落在 CIDR 内
+	}
+	if capacity == 0 {
+		return nil, fmt.Errorf("fakedns: pool cidr %q too small", cidr)
+	}
+
+	var base *big.Int
+	if is4 {
+		base = new(big.Int).SetBytes(ipnet.IP.To4())
+	} else {
+		base = new(big.Int).SetBytes(ipnet.IP.To16())
+	}
+	base.Add(base, big.NewInt(1)) // 从网络地址之后的第一个地址开始分配
+
+	return &ipPool{cidr: cidr, base: base, capacity: capacity, is4: is4}, nil
+}
+
+// addressAt 返回池内偏移 offset (< capacity) 处的地址
+func (p *ipPool) addressAt(offset uint64) net.IP {
+	value := new(big.Int).Add(p.base, new(big.Int).SetUint64(offset))
+	size := 4
+	if !p.is4 {
+		size = 16
+	}
+	raw := value.Bytes()
+	padded := make([]byte, size)
+	copy(padded[size-len(raw):], raw)
+	return net.IP(padded)
+}
+
+// next 返回游标当前指向的地址, 并把游标推进到下一个 (取模容量后折返)
+func (p *ipPool) next() net.IP {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ip := p.addressAt(p.cursor % p.capacity)
+	p.cursor++
+	return ip
+}
@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"strings"
+	"sync"
+)
+
+// acNode 是 Aho-Corasick 自动机中的一个节点
+type acNode struct {
+	children map[byte]*acNode
+	fail     *acNode
+	patterns []string // 恰好在本节点终止的关键词所属规则 ID
+	output   []string // patterns 与 fail 链上所有节点 output 的并集, 由 Build 计算
+}
+
+// ACMatcher 是针对一组关键词模式构建的 Aho-Corasick 自动机, 用于对查询域名做一次
+// 单趟扫描就能命中所有匹配的 "keywords" 规则, 弥补 DomainTrie 只能做精确标签/
+// *.前缀匹配、无法识别域名中任意位置子串的不足。
+type ACMatcher struct {
+	mu   sync.RWMutex
+	root *acNode
+}
+
+// NewACMatcher 创建一个空的自动机
+func NewACMatcher() *ACMatcher {
+	return &ACMatcher{root: newACNode()}
+}
+
+func newACNode() *acNode {
+	return &acNode{children: make(map[byte]*acNode)}
+}
+
+// AddPattern 把一个关键词模式加入 goto 字典树, 按字节建边; 大小写不敏感
+// 插入后需要调用 Build 重新计算 fail 链和 output 集合才能生效
+func (m *ACMatcher) AddPattern(pattern, ruleID string) {
+	pattern = strings.ToLower(pattern)
+	if pattern == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.root
+	for i := 0; i < len(pattern); i++ {
+		b := pattern[i]
+		child, ok := node.children[b]
+		if !ok {
+			child = newACNode()
+			node.children[b] = child
+		}
+		node = child
+	}
+	node.patterns = append(node.patterns, ruleID)
+}
+
+// Build 对 goto 字典树做 BFS, 计算每个节点的 fail 指针 (最深的、同时也是字典树中
+// 某个前缀的真后缀) 以及 output 集合 (当前节点自身的 patterns 并上 fail 节点的
+// output)。可以在新增模式后重复调用以重新生效, 每次都从 patterns 重新计算 output,
+// 不会因为重复调用而累积重复条目。
+func (m *ACMatcher) Build() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.root.fail = nil
+	m.root.output = nil
+
+	queue := make([]*acNode, 0, len(m.root.children))
+	for _, child := range m.root.children {
+		child.fail = m.root
+		child.output = cloneStrings(child.patterns)
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for b, child := range node.children {
+			fail := node.fail
+			for fail != nil {
+				if next, ok := fail.children[b]; ok {
+					child.fail = next
+					break
+				}
+				fail = fail.fail
+			}
+			if child.fail == nil {
+				child.fail = m.root
+			}
+			child.output = append(cloneStrings(child.patterns), child.fail.output...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// Match 对文本做单趟扫描, 按字节跟随 goto 边, 没有对应边时沿 fail 链回退, 返回
+// 扫描过程中命中的所有规则 ID (按首次命中的先后顺序, 已去重)
+func (m *ACMatcher) Match(text string) []string {
+	text = strings.ToLower(text)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node := m.root
+	var hits []string
+	var seen map[string]struct{}
+
+	for i := 0; i < len(text); i++ {
+		b := text[i]
+
+		for node != m.root {
+			if _, ok := node.children[b]; ok {
+				break
+			}
+			node = node.fail
+		}
+
+		if next, ok := node.children[b]; ok {
+			node = next
+		}
+
+		for _, id := range node.output {
+			if seen == nil {
+				seen = make(map[string]struct{})
+			}
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+			hits = append(hits, id)
+		}
+	}
+
+	return hits
+}
+
+func cloneStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	out := make([]string, len(s))
+	copy(out, s)
+	return out
+}
@@ -0,0 +1,144 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestACMatcher_MatchesOverlappingPatterns(t *testing.T) {
+	m := NewACMatcher()
+	m.AddPattern("phish", "rule-phish")
+	m.AddPattern("xn--", "rule-punycode")
+	m.AddPattern("cdn-", "rule-cdn")
+	m.Build()
+
+	hits := m.Match("login-phish-cdn-xn--example.com")
+	sort.Strings(hits)
+
+	want := []string{"rule-cdn", "rule-phish", "rule-punycode"}
+	if len(hits) != len(want) {
+		t.Fatalf("Match() = %v, want %v", hits, want)
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Fatalf("Match() = %v, want %v", hits, want)
+		}
+	}
+}
+
+func TestACMatcher_NoMatch(t *testing.T) {
+	m := NewACMatcher()
+	m.AddPattern("phish", "rule-phish")
+	m.Build()
+
+	if hits := m.Match("example.com"); hits != nil {
+		t.Fatalf("Match() = %v, want no hits", hits)
+	}
+}
+
+func TestACMatcher_CaseInsensitive(t *testing.T) {
+	m := NewACMatcher()
+	m.AddPattern("PHISH", "rule-phish")
+	m.Build()
+
+	hits := m.Match("Login-Phish.example.com")
+	if len(hits) != 1 || hits[0] != "rule-phish" {
+		t.Fatalf("Match() = %v, want [rule-phish]", hits)
+	}
+}
+
+func TestACMatcher_SharedSuffixFailLinks(t *testing.T) {
+	// "she", "he", "hers" 共享后缀, 用于验证 fail 链在 goto 边缺失时正确回退
+	m := NewACMatcher()
+	m.AddPattern("she", "rule-she")
+	m.AddPattern("he", "rule-he")
+	m.AddPattern("hers", "rule-hers")
+	m.Build()
+
+	hits := m.Match("ushers")
+	sort.Strings(hits)
+
+	want := []string{"rule-he", "rule-hers", "rule-she"}
+	if len(hits) != len(want) {
+		t.Fatalf("Match() = %v, want %v", hits, want)
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Fatalf("Match() = %v, want %v", hits, want)
+		}
+	}
+}
+
+func TestACMatcher_DeduplicatesRepeatedHits(t *testing.T) {
+	m := NewACMatcher()
+	m.AddPattern("ab", "rule-ab")
+	m.Build()
+
+	hits := m.Match("ababab")
+	if len(hits) != 1 || hits[0] != "rule-ab" {
+		t.Fatalf("Match() = %v, want [rule-ab]", hits)
+	}
+}
+
+func TestACMatcher_RebuildAfterIncrementalAddPattern(t *testing.T) {
+	m := NewACMatcher()
+	m.AddPattern("foo", "rule-foo")
+	m.Build()
+
+	if hits := m.Match("foobar"); len(hits) != 1 || hits[0] != "rule-foo" {
+		t.Fatalf("Match() before second AddPattern = %v", hits)
+	}
+
+	m.AddPattern("bar", "rule-bar")
+	m.Build()
+
+	hits := m.Match("foobar")
+	sort.Strings(hits)
+	want := []string{"rule-bar", "rule-foo"}
+	if len(hits) != len(want) || hits[0] != want[0] || hits[1] != want[1] {
+		t.Fatalf("Match() after second AddPattern = %v, want %v", hits, want)
+	}
+}
+
+func TestEngine_CheckMatchesKeywordRule(t *testing.T) {
+	engine, err := NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	engine.AddRule(Rule{ID: "block-phish", Enabled: true, Action: ActionBlock, Keywords: []string{"phish"}})
+
+	msg := createTestDNSMessage("login-phish.example.com")
+	action, rule := engine.Check(msg, "1.2.3.4")
+	if action != ActionBlock || rule == nil || rule.ID != "block-phish" {
+		t.Fatalf("Check() = (%v, %v), want ActionBlock/block-phish", action, rule)
+	}
+}
+
+// synthetic10kKeywords 构造一份 10k 规模的合成关键词黑名单, 供基准测试使用
+func synthetic10kKeywords() []string {
+	keywords := make([]string, 10000)
+	for i := range keywords {
+		keywords[i] = fmt.Sprintf("bad-keyword-%d", i)
+	}
+	return keywords
+}
+
+func BenchmarkACMatcher_Match(b *testing.B) {
+	m := NewACMatcher()
+	for _, kw := range synthetic10kKeywords() {
+		m.AddPattern(kw, kw)
+	}
+	m.Build()
+
+	queries := []string{
+		"www.example.com",
+		"login.bad-keyword-4242.example.com",
+		"a.b.c.d.some-very-long-subdomain-chain.example.org",
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Match(queries[i%len(queries)])
+	}
+}
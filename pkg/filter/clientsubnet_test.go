@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xdp-dns/pkg/dns"
+)
+
+func TestConvertRuleConfig_ParsesClientSubnets(t *testing.T) {
+	rc := RuleConfig{
+		ID:            "internal-only",
+		Action:        "block",
+		Domains:       []string{"internal.example.com"},
+		ClientSubnets: []string{"10.0.0.0/8", "2001:db8::/32"},
+		StripECS:      true,
+		OverwriteECS:  "203.0.113.0/24",
+	}
+
+	rule, err := convertRuleConfig(rc)
+	if err != nil {
+		t.Fatalf("convertRuleConfig() error = %v", err)
+	}
+	if len(rule.ClientSubnets) != 2 {
+		t.Fatalf("ClientSubnets = %+v, want 2 entries", rule.ClientSubnets)
+	}
+	if !rule.StripECS {
+		t.Error("StripECS = false, want true")
+	}
+	if rule.OverwriteECS == nil || rule.OverwriteECS.String() != "203.0.113.0/24" {
+		t.Fatalf("OverwriteECS = %v, want 203.0.113.0/24", rule.OverwriteECS)
+	}
+}
+
+func TestConvertRuleConfig_InvalidClientSubnetIsError(t *testing.T) {
+	rc := RuleConfig{ID: "bad", Action: "block", ClientSubnets: []string{"not-a-cidr"}}
+	if _, err := convertRuleConfig(rc); err == nil {
+		t.Fatal("convertRuleConfig() with an invalid client_subnets entry should error")
+	}
+}
+
+func TestEngine_CheckDomain_ClientSubnetRestrictsMatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	const yamlRules = `
+rules:
+  - id: block-internal-clients
+    priority: 100
+    enabled: true
+    action: block
+    domains:
+      - restricted.example.com
+    client_subnets:
+      - 10.0.0.0/8
+`
+	if err := os.WriteFile(path, []byte(yamlRules), 0644); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+
+	engine, err := NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	inside := &CheckContext{ClientIP: []byte{10, 0, 0, 5}}
+	result, err := engine.CheckDomain("restricted.example.com", dns.TypeA, inside)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionBlock {
+		t.Fatalf("Action = %v, want ActionBlock for a client inside 10.0.0.0/8", result.Action)
+	}
+
+	outside := &CheckContext{ClientIP: []byte{8, 8, 8, 8}}
+	result, err = engine.CheckDomain("restricted.example.com", dns.TypeA, outside)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionAllow {
+		t.Fatalf("Action = %v, want ActionAllow for a client outside 10.0.0.0/8", result.Action)
+	}
+
+	result, err = engine.CheckDomain("restricted.example.com", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionAllow {
+		t.Fatalf("Action = %v, want ActionAllow when no CheckContext is available", result.Action)
+	}
+}
+
+func TestNewCheckContext_PrefersECSOverSrcIP(t *testing.T) {
+	edns := &dns.EDNS{Options: []dns.EDNSOption{
+		{Code: dns.EDNSOptionECS, Data: []byte{0, 1, 24, 0, 198, 51, 100, 0}},
+	}}
+
+	ctx := NewCheckContext("8.8.8.8", edns)
+	if ctx.ClientIP.String() != "198.51.100.0" {
+		t.Fatalf("ClientIP = %v, want ECS address 198.51.100.0 to take priority over srcIP", ctx.ClientIP)
+	}
+}
+
+func TestNewCheckContext_FallsBackToSrcIPWithoutECS(t *testing.T) {
+	ctx := NewCheckContext("8.8.8.8", nil)
+	if ctx.ClientIP.String() != "8.8.8.8" {
+		t.Fatalf("ClientIP = %v, want srcIP 8.8.8.8 when no ECS present", ctx.ClientIP)
+	}
+}
@@ -1,33 +1,87 @@
 package filter
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"os"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/ratelimit"
 )
 
+// rateLimitRefillInterval 是令牌桶补充令牌的周期, 见 ratelimit.Limiter.Run
+const rateLimitRefillInterval = 1 * time.Second
+
 // Engine 过滤引擎
+//
+// domainTrie 用 atomic.Pointer 存放, 使 Check/CheckDomain 的精确匹配查找无需持有
+// e.mu 就能安全读取当前生效的 Trie: 批量重载 (LoadRules) 在锁外把新规则插入一棵
+// 全新的 Trie, 只在构建完成后做一次指针替换, 不会在重建期间让查询路径长时间阻塞。
 type Engine struct {
-	rules      []Rule
-	domainTrie *DomainTrie
-	mu         sync.RWMutex
-	stats      EngineStats
+	rules         []Rule
+	domainTrie    atomic.Pointer[DomainTrie]
+	acMatcher     atomic.Pointer[ACMatcher] // keywords 规则的 Aho-Corasick 自动机, 原理同 domainTrie
+	mu            sync.RWMutex
+	stats         EngineStats
+	sinks         []Sink
+	logSampling   map[Action]float64     // 按动作采样导出到 Sink 的比例, 缺省为 1.0 (全部导出)
+	limiter       *ratelimit.Limiter     // 按来源 IP/CIDR 的令牌桶限速, 用户态慢路径执行, 未配置时为 nil (不限速)
+	limiterCancel context.CancelFunc     // 停止 limiter 的令牌补充 goroutine, LoadRules 换上新 limiter 前先取消旧的
+	ruleSources   []registeredRuleSource // 已注册的可插拔规则来源 (hosts/AdGuard/dnsmasq/域名列表), 见 rulesource.go
+}
+
+// SetSink 注册唯一的决策导出 Sink (如 dnstap), 替换之前所有已注册的 Sink, 传入 nil 可清空
+func (e *Engine) SetSink(sink Sink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if sink == nil {
+		e.sinks = nil
+		return
+	}
+	e.sinks = []Sink{sink}
+}
+
+// AddSink 追加一个决策导出 Sink, 允许多个观测者 (如 dnstap 日志 + RPZ 命中计数) 同时生效
+func (e *Engine) AddSink(sink Sink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sinks = append(e.sinks, sink)
+}
+
+// emitDecision 在采样比例允许的情况下把一次决策推给所有已注册的 Sink, 调用方需持有至少读锁
+func (e *Engine) emitDecision(d Decision) {
+	if len(e.sinks) == 0 {
+		return
+	}
+	rate, ok := e.logSampling[d.Action]
+	if !ok {
+		rate = 1.0
+	}
+	if rate < 1.0 && rand.Float64() >= rate {
+		return
+	}
+	for _, sink := range e.sinks {
+		sink.OnDecision(d)
+	}
 }
 
 // NewEngine 创建新的过滤引擎
 func NewEngine(rulesPath string) (*Engine, error) {
 	e := &Engine{
-		domainTrie: NewDomainTrie(),
-		rules:      make([]Rule, 0),
+		rules: make([]Rule, 0),
 	}
+	e.domainTrie.Store(NewDomainTrie())
+	e.acMatcher.Store(NewACMatcher())
 
 	if rulesPath != "" {
 		if err := e.LoadRules(rulesPath); err != nil {
@@ -39,6 +93,10 @@ func NewEngine(rulesPath string) (*Engine, error) {
 }
 
 // LoadRules 从文件加载规则
+//
+// 新规则集在锁外完整构建好 (包括一棵全新的 DomainTrie), 只有在全部解析校验通过后才
+// 原子替换生效状态; 期间 Check/CheckDomain 读取到的始终是替换前完整可用的旧状态,
+// 不会因为重建耗时而被阻塞, 校验失败时生效规则保持不变 (回滚)。
 func (e *Engine) LoadRules(path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -50,53 +108,153 @@ func (e *Engine) LoadRules(path string) error {
 		return fmt.Errorf("failed to parse rules: %w", err)
 	}
 
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	// 清空现有规则
-	e.rules = make([]Rule, 0, len(ruleSet.Rules))
-	e.domainTrie = NewDomainTrie()
-
-	// 加载规则
+	newRules := make([]Rule, 0, len(ruleSet.Rules))
+	newTrie := NewDomainTrie()
+	newACMatcher := NewACMatcher()
 	for _, rc := range ruleSet.Rules {
-		rule := e.convertRuleConfig(rc)
-		e.addRuleInternal(rule)
+		rule, err := convertRuleConfig(rc)
+		if err != nil {
+			return fmt.Errorf("failed to load rules: %w", err)
+		}
+		newRules = append(newRules, rule)
+		for _, domain := range rule.Domains {
+			if !strings.HasPrefix(domain, "*") {
+				newTrie.Insert(domain, &rule)
+			}
+		}
+		for _, keyword := range rule.Keywords {
+			newACMatcher.AddPattern(keyword, rule.ID)
+		}
 	}
-
-	// 按优先级排序 (高优先级在前)
-	sort.Slice(e.rules, func(i, j int) bool {
-		return e.rules[i].Priority > e.rules[j].Priority
+	newACMatcher.Build()
+	sort.Slice(newRules, func(i, j int) bool {
+		return newRules[i].Priority > newRules[j].Priority
 	})
 
+	var newLimiter *ratelimit.Limiter
+	if len(ruleSet.RateLimits) > 0 {
+		newLimiter, err = ratelimit.NewLimiter(convertRateLimits(ruleSet.RateLimits))
+		if err != nil {
+			return fmt.Errorf("failed to load rate limits: %w", err)
+		}
+	}
+	newLogSampling := convertLogSampling(ruleSet.LogSampling)
+
+	// 全部构建并校验通过, 原子生效
+	e.mu.Lock()
+	e.rules = newRules
+	e.logSampling = newLogSampling
+	if e.limiterCancel != nil {
+		e.limiterCancel()
+		e.limiterCancel = nil
+	}
+	e.limiter = newLimiter
+	if newLimiter != nil {
+		limiterCtx, cancel := context.WithCancel(context.Background())
+		e.limiterCancel = cancel
+		go newLimiter.Run(limiterCtx, rateLimitRefillInterval)
+	}
+	e.mu.Unlock()
+	e.domainTrie.Store(newTrie)
+	e.acMatcher.Store(newACMatcher)
+
 	return nil
 }
 
-// convertRuleConfig 转换规则配置
-func (e *Engine) convertRuleConfig(rc RuleConfig) Rule {
+// Close 停止引擎持有的后台 goroutine (目前只有限速令牌桶的周期补充), 引擎本身随后
+// 不应再被使用
+func (e *Engine) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.limiterCancel != nil {
+		e.limiterCancel()
+		e.limiterCancel = nil
+	}
+}
+
+// convertRuleConfig 把 YAML 规则配置转换为内部 Rule, 遇到未知动作或非法重定向 IP 时
+// 返回错误, 由调用方 (LoadRules) 据此回滚整次重载而不是静默把规则当成放行处理
+func convertRuleConfig(rc RuleConfig) (Rule, error) {
 	rule := Rule{
 		ID:          rc.ID,
 		Priority:    rc.Priority,
 		Enabled:     rc.Enabled,
 		Domains:     rc.Domains,
+		Keywords:    rc.Keywords,
 		RedirectTTL: rc.RedirectTTL,
 		Description: rc.Description,
 	}
 
-	// 转换动作
+	// 转换动作; 省略不填时默认放行, 但填了无法识别的值视为配置错误, 拒绝整次重载
 	switch strings.ToLower(rc.Action) {
+	case "", "allow":
+		rule.Action = ActionAllow
 	case "block":
 		rule.Action = ActionBlock
 	case "redirect":
 		rule.Action = ActionRedirect
 	case "log":
 		rule.Action = ActionLog
+	case "rewrite":
+		rule.Action = ActionRewrite
+	case "fakedns":
+		rule.Action = ActionFakeDNS
+	case "forward":
+		rule.Action = ActionForward
+	case "answer":
+		rule.Action = ActionAnswer
 	default:
-		rule.Action = ActionAllow
+		return Rule{}, fmt.Errorf("rule %q: unknown action %q", rc.ID, rc.Action)
+	}
+
+	if rule.Action == ActionRewrite {
+		if rc.Rewrite == nil {
+			return Rule{}, fmt.Errorf("rule %q: action rewrite requires a rewrite config", rc.ID)
+		}
+		rewrite, err := convertRewriteConfig(rc.ID, rc.Rewrite)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.Rewrite = rewrite
+	}
+
+	if rule.Action == ActionForward {
+		if len(rc.Forwarders) == 0 {
+			return Rule{}, fmt.Errorf("rule %q: action forward requires at least one forwarder", rc.ID)
+		}
+		for _, fc := range rc.Forwarders {
+			if fc.Addr == "" {
+				return Rule{}, fmt.Errorf("rule %q: forwarder entry missing addr", rc.ID)
+			}
+			rule.Forwarders = append(rule.Forwarders, Upstream{Addr: fc.Addr, StartDelay: fc.StartDelay})
+		}
+	}
+
+	if rule.Action == ActionAnswer {
+		local, err := convertLocalAnswerConfig(rc.ID, rc.LocalAnswer)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.LocalAnswer = local
+	}
+
+	// 展开反向解析 CIDR 为 in-addr.arpa/ip6.arpa 域名, 并入普通的 Domains 匹配
+	// 列表, 复用同一套 Trie/通配符匹配逻辑, 不需要改动 Engine.Check/CheckDomain
+	for _, cidr := range rc.ReverseCIDRs {
+		zones, err := ReverseCIDRDomains(cidr)
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %q: %w", rc.ID, err)
+		}
+		rule.Domains = append(rule.Domains, zones...)
 	}
 
 	// 转换重定向IP
 	if rc.RedirectIP != "" {
-		rule.RedirectIP = net.ParseIP(rc.RedirectIP)
+		ip := net.ParseIP(rc.RedirectIP)
+		if ip == nil {
+			return Rule{}, fmt.Errorf("rule %q: invalid redirect_ip %q", rc.ID, rc.RedirectIP)
+		}
+		rule.RedirectIP = ip
 	}
 
 	// 转换查询类型
@@ -123,19 +281,186 @@ func (e *Engine) convertRuleConfig(rc RuleConfig) Rule {
 		rule.RedirectTTL = 300 // 默认 5 分钟
 	}
 
-	return rule
+	// 转换客户端子网 CIDR 列表
+	for _, cidr := range rc.ClientSubnets {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %q: invalid client_subnets entry %q: %w", rc.ID, cidr, err)
+		}
+		rule.ClientSubnets = append(rule.ClientSubnets, subnet)
+	}
+
+	rule.StripECS = rc.StripECS
+	if rc.OverwriteECS != "" {
+		_, subnet, err := net.ParseCIDR(rc.OverwriteECS)
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %q: invalid overwrite_ecs %q: %w", rc.ID, rc.OverwriteECS, err)
+		}
+		rule.OverwriteECS = subnet
+	}
+
+	return rule, nil
+}
+
+// convertLocalAnswerConfig 把 YAML 的 local_answer 配置转换为内部 LocalAnswer,
+// 要求至少配置一条 A、AAAA 或 PTR 记录
+func convertLocalAnswerConfig(ruleID string, rc *LocalAnswerConfig) (*LocalAnswer, error) {
+	if rc == nil || (len(rc.A) == 0 && len(rc.AAAA) == 0 && rc.PTR == "") {
+		return nil, fmt.Errorf("rule %q: action answer requires at least one local_answer A, AAAA or PTR record", ruleID)
+	}
+
+	local := &LocalAnswer{PTR: rc.PTR, TTL: rc.TTL}
+	if local.TTL == 0 {
+		local.TTL = 300 // 默认 5 分钟, 与 RedirectTTL 的默认值一致
+	}
+
+	for _, a := range rc.A {
+		ip := net.ParseIP(a).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("rule %q: invalid local_answer A ip %q", ruleID, a)
+		}
+		local.A = append(local.A, ip)
+	}
+	for _, a := range rc.AAAA {
+		ip := net.ParseIP(a)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("rule %q: invalid local_answer AAAA ip %q", ruleID, a)
+		}
+		local.AAAA = append(local.AAAA, ip)
+	}
+
+	return local, nil
+}
+
+// convertRewriteConfig 把 YAML 的 rewrite 配置转换为内部 Rewrite, 遇到非法 rcode
+// 名称或非法 IP 时返回错误, 由调用方据此回滚整次重载
+func convertRewriteConfig(ruleID string, rc *RewriteConfig) (*Rewrite, error) {
+	rewrite := &Rewrite{NoData: rc.NoData, CNAME: rc.CNAME}
+
+	if rc.RCode != "" {
+		code, err := parseRCode(rc.RCode)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", ruleID, err)
+		}
+		rewrite.RCode = &code
+	}
+
+	for _, a := range rc.A {
+		ip := net.ParseIP(a.IP).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("rule %q: invalid rewrite A ip %q", ruleID, a.IP)
+		}
+		rewrite.A = append(rewrite.A, RewriteA{IP: ip, TTL: a.TTL})
+	}
+	for _, a := range rc.AAAA {
+		ip := net.ParseIP(a.IP)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("rule %q: invalid rewrite AAAA ip %q", ruleID, a.IP)
+		}
+		rewrite.AAAA = append(rewrite.AAAA, RewriteAAAA{IP: ip, TTL: a.TTL})
+	}
+	for _, mx := range rc.MX {
+		rewrite.MX = append(rewrite.MX, RewriteMX{Preference: mx.Preference, Exchange: mx.Exchange, TTL: mx.TTL})
+	}
+	for _, txt := range rc.TXT {
+		rewrite.TXT = append(rewrite.TXT, RewriteTXT{Text: txt.Text, TTL: txt.TTL})
+	}
+	for _, ptr := range rc.PTR {
+		rewrite.PTR = append(rewrite.PTR, RewritePTR{Target: ptr.Target, TTL: ptr.TTL})
+	}
+	for _, srv := range rc.SRV {
+		rewrite.SRV = append(rewrite.SRV, RewriteSRV{
+			Priority: srv.Priority, Weight: srv.Weight, Port: srv.Port, Target: srv.Target, TTL: srv.TTL,
+		})
+	}
+	for _, svcb := range rc.HTTPS {
+		rewrite.HTTPS = append(rewrite.HTTPS, RewriteSVCB{Priority: svcb.Priority, Target: svcb.Target, TTL: svcb.TTL})
+	}
+	for _, svcb := range rc.SVCB {
+		rewrite.SVCB = append(rewrite.SVCB, RewriteSVCB{Priority: svcb.Priority, Target: svcb.Target, TTL: svcb.TTL})
+	}
+
+	return rewrite, nil
+}
+
+// parseRCode 把 rcode 名称解析为 dns 包里的响应码常量
+func parseRCode(name string) (uint16, error) {
+	switch strings.ToLower(name) {
+	case "noerror":
+		return dns.RCodeNoError, nil
+	case "nxdomain":
+		return dns.RCodeNXDomain, nil
+	case "refused":
+		return dns.RCodeRefused, nil
+	case "servfail":
+		return dns.RCodeServerFailure, nil
+	default:
+		return 0, fmt.Errorf("unknown rcode %q", name)
+	}
+}
+
+// convertRateLimits 把 filter.RateLimitConfig 转换为 ratelimit 包自己的配置类型,
+// 两个包不互相依赖对方的类型, 避免 import 循环
+func convertRateLimits(configs []RateLimitConfig) []ratelimit.Config {
+	out := make([]ratelimit.Config, len(configs))
+	for i, rc := range configs {
+		out[i] = ratelimit.Config{Source: rc.Source, QueriesPerSecond: rc.QueriesPerSecond, Burst: rc.Burst}
+	}
+	return out
+}
+
+// convertLogSampling 把 YAML 中以动作名为键的采样比例转换为按 Action 索引
+func convertLogSampling(raw map[string]float64) map[Action]float64 {
+	sampling := make(map[Action]float64, len(raw))
+	for name, rate := range raw {
+		switch strings.ToLower(name) {
+		case "allow":
+			sampling[ActionAllow] = rate
+		case "block":
+			sampling[ActionBlock] = rate
+		case "redirect":
+			sampling[ActionRedirect] = rate
+		case "log":
+			sampling[ActionLog] = rate
+		}
+	}
+	return sampling
 }
 
-// addRuleInternal 内部添加规则 (无锁)
+// addRuleInternal 内部添加规则 (无锁), 在当前生效的 Trie 和 Aho-Corasick 自动机上原地插入
 func (e *Engine) addRuleInternal(rule Rule) {
 	e.rules = append(e.rules, rule)
 
 	// 将精确域名添加到 Trie
+	trie := e.domainTrie.Load()
 	for _, domain := range rule.Domains {
 		if !strings.HasPrefix(domain, "*") {
-			e.domainTrie.Insert(domain, &rule)
+			trie.Insert(domain, &rule)
+		}
+	}
+
+	// 将关键词加入自动机; AddPattern 本身可安全地增量调用, 但 fail 链/output 需要
+	// 重新 Build 才会反映新模式, 规则数量不大, 每次添加都整体重建一次
+	if len(rule.Keywords) > 0 {
+		matcher := e.acMatcher.Load()
+		for _, keyword := range rule.Keywords {
+			matcher.AddPattern(keyword, rule.ID)
+		}
+		matcher.Build()
+	}
+}
+
+// rebuildACMatcher 用当前 e.rules 的关键词重新构建一份全新的自动机并原子替换生效,
+// 用于 RemoveRule: Aho-Corasick 自动机不支持增量删除模式, 只能整体重建
+func (e *Engine) rebuildACMatcher() {
+	matcher := NewACMatcher()
+	for i := range e.rules {
+		for _, keyword := range e.rules[i].Keywords {
+			matcher.AddPattern(keyword, e.rules[i].ID)
 		}
 	}
+	matcher.Build()
+	e.acMatcher.Store(matcher)
 }
 
 // Check 检查 DNS 消息
@@ -144,14 +469,23 @@ func (e *Engine) Check(msg *dns.Message, srcIP string) (Action, *Rule) {
 
 	domain := msg.GetQueryDomain()
 	qtype := msg.GetQueryType()
+	ctx := NewCheckContext(srcIP, msg.EDNS)
 
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	// 0. 速率限制 (用户态慢路径; 明显的洪泛流量预期已被内核态 XDP 程序提前丢弃)
+	if e.limiter != nil && !e.limiter.Allow(srcIP) {
+		e.updateStats(ActionBlock)
+		e.emitDecision(Decision{SrcIP: srcIP, Domain: domain, QType: qtype, Action: ActionBlock, RuleID: "ratelimit", RawQuery: msg.RawData})
+		return ActionBlock, nil
+	}
+
 	// 1. 精确域名匹配 (Trie 查找)
-	if rule := e.domainTrie.Match(domain); rule != nil && rule.Enabled {
-		if e.matchQueryType(rule, qtype) {
+	if rule := e.domainTrie.Load().Match(domain); rule != nil && rule.Enabled {
+		if e.matchQueryType(rule, qtype) && e.matchClientSubnet(rule, ctx) {
 			e.updateStats(rule.Action)
+			e.emitDecision(Decision{SrcIP: srcIP, Domain: domain, QType: qtype, Action: rule.Action, RuleID: rule.ID, RawQuery: msg.RawData})
 			return rule.Action, rule
 		}
 	}
@@ -163,35 +497,75 @@ func (e *Engine) Check(msg *dns.Message, srcIP string) (Action, *Rule) {
 			continue
 		}
 		if e.matchDomainPatterns(domain, rule.Domains) {
-			if e.matchQueryType(rule, qtype) {
+			if e.matchQueryType(rule, qtype) && e.matchClientSubnet(rule, ctx) {
 				e.updateStats(rule.Action)
+				e.emitDecision(Decision{SrcIP: srcIP, Domain: domain, QType: qtype, Action: rule.Action, RuleID: rule.ID, RawQuery: msg.RawData})
 				return rule.Action, rule
 			}
 		}
 	}
 
+	// 3. 关键词匹配 (Aho-Corasick 自动机单趟扫描)
+	if rule := e.matchKeywords(domain, qtype, ctx); rule != nil {
+		e.updateStats(rule.Action)
+		e.emitDecision(Decision{SrcIP: srcIP, Domain: domain, QType: qtype, Action: rule.Action, RuleID: rule.ID, RawQuery: msg.RawData})
+		return rule.Action, rule
+	}
+
 	atomic.AddUint64(&e.stats.Allowed, 1)
+	e.emitDecision(Decision{SrcIP: srcIP, Domain: domain, QType: qtype, Action: ActionAllow, RawQuery: msg.RawData})
 	return ActionAllow, nil
 }
 
+// matchKeywords 用 Aho-Corasick 自动机对域名做一次单趟扫描, 在命中的规则 ID 中
+// 按优先级 (e.rules 已按 Priority 降序排列) 取第一条启用且查询类型匹配的规则
+func (e *Engine) matchKeywords(domain string, qtype uint16, ctx *CheckContext) *Rule {
+	hits := e.acMatcher.Load().Match(domain)
+	if len(hits) == 0 {
+		return nil
+	}
+	hitSet := make(map[string]struct{}, len(hits))
+	for _, id := range hits {
+		hitSet[id] = struct{}{}
+	}
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.Enabled {
+			continue
+		}
+		if _, ok := hitSet[rule.ID]; !ok {
+			continue
+		}
+		if e.matchQueryType(rule, qtype) && e.matchClientSubnet(rule, ctx) {
+			return rule
+		}
+	}
+	return nil
+}
+
 // CheckDomain 检查域名 - 用于混合架构
-// 接收已解析的域名和查询类型，返回匹配结果
-func (e *Engine) CheckDomain(domain string, qtype uint16) (*CheckResult, error) {
+// 接收已解析的域名和查询类型, 以及可选的 CheckContext (携带客户端子网等额外匹配
+// 信息, 调用方没有时可传 nil, 此时带 ClientSubnets 的规则永远不匹配), 返回匹配结果
+func (e *Engine) CheckDomain(domain string, qtype uint16, ctx *CheckContext) (*CheckResult, error) {
 	atomic.AddUint64(&e.stats.TotalChecks, 1)
 
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
 	// 1. 精确域名匹配 (Trie 查找)
-	if rule := e.domainTrie.Match(domain); rule != nil && rule.Enabled {
-		if e.matchQueryType(rule, qtype) {
+	if rule := e.domainTrie.Load().Match(domain); rule != nil && rule.Enabled {
+		if e.matchQueryType(rule, qtype) && e.matchClientSubnet(rule, ctx) {
 			e.updateStats(rule.Action)
+			e.emitDecision(Decision{Domain: domain, QType: qtype, Action: rule.Action, RuleID: rule.ID})
 			return &CheckResult{
-				Action:     rule.Action,
-				Rule:       rule,
-				RuleID:     rule.ID,
-				RedirectIP: rule.RedirectIP,
-				TTL:        rule.RedirectTTL,
+				Action:       rule.Action,
+				Rule:         rule,
+				RuleID:       rule.ID,
+				RedirectIP:   rule.RedirectIP,
+				TTL:          rule.RedirectTTL,
+				Rewrite:      rule.Rewrite,
+				StripECS:     rule.StripECS,
+				OverwriteECS: rule.OverwriteECS,
 			}, nil
 		}
 	}
@@ -203,20 +577,25 @@ func (e *Engine) CheckDomain(domain string, qtype uint16) (*CheckResult, error)
 			continue
 		}
 		if e.matchDomainPatterns(domain, rule.Domains) {
-			if e.matchQueryType(rule, qtype) {
+			if e.matchQueryType(rule, qtype) && e.matchClientSubnet(rule, ctx) {
 				e.updateStats(rule.Action)
+				e.emitDecision(Decision{Domain: domain, QType: qtype, Action: rule.Action, RuleID: rule.ID})
 				return &CheckResult{
-					Action:     rule.Action,
-					Rule:       rule,
-					RuleID:     rule.ID,
-					RedirectIP: rule.RedirectIP,
-					TTL:        rule.RedirectTTL,
+					Action:       rule.Action,
+					Rule:         rule,
+					RuleID:       rule.ID,
+					RedirectIP:   rule.RedirectIP,
+					TTL:          rule.RedirectTTL,
+					Rewrite:      rule.Rewrite,
+					StripECS:     rule.StripECS,
+					OverwriteECS: rule.OverwriteECS,
 				}, nil
 			}
 		}
 	}
 
 	atomic.AddUint64(&e.stats.Allowed, 1)
+	e.emitDecision(Decision{Domain: domain, QType: qtype, Action: ActionAllow})
 	return &CheckResult{Action: ActionAllow}, nil
 }
 
@@ -264,6 +643,23 @@ func (e *Engine) matchQueryType(rule *Rule, qtype uint16) bool {
 	return false
 }
 
+// matchClientSubnet 检查 ctx 所携带的客户端地址是否落在 rule.ClientSubnets 中的
+// 任意一个 CIDR 内; 规则没有配置 ClientSubnets 时总是匹配 (不作限制)
+func (e *Engine) matchClientSubnet(rule *Rule, ctx *CheckContext) bool {
+	if len(rule.ClientSubnets) == 0 {
+		return true
+	}
+	if ctx == nil || ctx.ClientIP == nil {
+		return false
+	}
+	for _, subnet := range rule.ClientSubnets {
+		if subnet.Contains(ctx.ClientIP) {
+			return true
+		}
+	}
+	return false
+}
+
 // updateStats 更新统计
 func (e *Engine) updateStats(action Action) {
 	switch action {
@@ -300,10 +696,13 @@ func (e *Engine) RemoveRule(id string) bool {
 		if rule.ID == id {
 			// 从 Trie 中移除域名
 			for _, domain := range rule.Domains {
-				e.domainTrie.Remove(domain)
+				e.domainTrie.Load().Remove(domain)
 			}
 			// 从规则列表中移除
 			e.rules = append(e.rules[:i], e.rules[i+1:]...)
+			if len(rule.Keywords) > 0 {
+				e.rebuildACMatcher()
+			}
 			return true
 		}
 	}
@@ -372,3 +771,104 @@ func (e *Engine) DisableRule(id string) bool {
 	}
 	return false
 }
+
+// Snapshot 当前生效规则集的只读快照, 供比较两次重载之间的差异使用
+type Snapshot struct {
+	Rules []Rule
+}
+
+// Snapshot 返回当前生效规则集的一份拷贝
+func (e *Engine) Snapshot() Snapshot {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := make([]Rule, len(e.rules))
+	copy(rules, e.rules)
+	return Snapshot{Rules: rules}
+}
+
+// RuleDiff 描述两次 Snapshot 之间规则集合的变化, 按 ID 比较
+type RuleDiff struct {
+	Added    []string // 只出现在新快照中的规则 ID
+	Removed  []string // 只出现在旧快照中的规则 ID
+	Modified []string // 两侧都有但内容不同的规则 ID
+}
+
+// Diff 计算从 s (旧) 到 other (新) 的规则变化
+func (s Snapshot) Diff(other Snapshot) RuleDiff {
+	oldByID := make(map[string]Rule, len(s.Rules))
+	for _, r := range s.Rules {
+		oldByID[r.ID] = r
+	}
+	newByID := make(map[string]Rule, len(other.Rules))
+	for _, r := range other.Rules {
+		newByID[r.ID] = r
+	}
+
+	var diff RuleDiff
+	for id, newRule := range newByID {
+		oldRule, existed := oldByID[id]
+		if !existed {
+			diff.Added = append(diff.Added, id)
+			continue
+		}
+		if !rulesEqual(oldRule, newRule) {
+			diff.Modified = append(diff.Modified, id)
+		}
+	}
+	for id := range oldByID {
+		if _, stillExists := newByID[id]; !stillExists {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff
+}
+
+// rulesEqual 比较两条规则除 ID 以外的字段是否相同
+func rulesEqual(a, b Rule) bool {
+	if a.Priority != b.Priority || a.Enabled != b.Enabled || a.Action != b.Action ||
+		a.RedirectTTL != b.RedirectTTL || a.Description != b.Description {
+		return false
+	}
+	if !a.RedirectIP.Equal(b.RedirectIP) {
+		return false
+	}
+	if !equalStringSlices(a.Domains, b.Domains) || !equalStringSlices(a.Keywords, b.Keywords) {
+		return false
+	}
+	if a.StripECS != b.StripECS || !reflect.DeepEqual(a.OverwriteECS, b.OverwriteECS) ||
+		!reflect.DeepEqual(a.ClientSubnets, b.ClientSubnets) || !reflect.DeepEqual(a.Rewrite, b.Rewrite) ||
+		!reflect.DeepEqual(a.Forwarders, b.Forwarders) {
+		return false
+	}
+	la, lb := a.LocalAnswer, b.LocalAnswer
+	if (la == nil) != (lb == nil) || la != nil && (la.PTR != lb.PTR || la.TTL != lb.TTL ||
+		!reflect.DeepEqual(la.A, lb.A) || !reflect.DeepEqual(la.AAAA, lb.AAAA)) {
+		return false
+	}
+	if len(a.QueryTypes) != len(b.QueryTypes) {
+		return false
+	}
+	for i := range a.QueryTypes {
+		if a.QueryTypes[i] != b.QueryTypes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
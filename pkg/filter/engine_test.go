@@ -118,6 +118,52 @@ func TestMatchDomainPattern(t *testing.T) {
 	}
 }
 
+type fakeSink struct {
+	decisions []Decision
+}
+
+func (s *fakeSink) OnDecision(d Decision) {
+	s.decisions = append(s.decisions, d)
+}
+
+func TestEngine_EmitsDecisionsToSink(t *testing.T) {
+	engine, _ := NewEngine("")
+	engine.AddRule(Rule{
+		ID:      "block-ads",
+		Enabled: true,
+		Action:  ActionBlock,
+		Domains: []string{"ads.example.com"},
+	})
+
+	sink := &fakeSink{}
+	engine.SetSink(sink)
+
+	msg := createTestDNSMessage("ads.example.com")
+	engine.Check(msg, "192.168.1.1")
+
+	if len(sink.decisions) != 1 {
+		t.Fatalf("sink received %d decisions, want 1", len(sink.decisions))
+	}
+	if sink.decisions[0].Action != ActionBlock || sink.decisions[0].RuleID != "block-ads" {
+		t.Errorf("decision = %+v, want Action=Block RuleID=block-ads", sink.decisions[0])
+	}
+}
+
+func TestEngine_LogSamplingSkipsDecisions(t *testing.T) {
+	engine, _ := NewEngine("")
+	engine.logSampling = map[Action]float64{ActionAllow: 0}
+
+	sink := &fakeSink{}
+	engine.SetSink(sink)
+
+	msg := createTestDNSMessage("allowed.example.com")
+	engine.Check(msg, "192.168.1.1")
+
+	if len(sink.decisions) != 0 {
+		t.Errorf("sink received %d decisions with sampling rate 0, want 0", len(sink.decisions))
+	}
+}
+
 // createTestDNSMessage 创建测试用 DNS 消息
 func createTestDNSMessage(domain string) *dns.Message {
 	return &dns.Message{
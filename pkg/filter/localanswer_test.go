@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xdp-dns/pkg/dns"
+)
+
+func TestConvertRuleConfig_ParsesLocalAnswer(t *testing.T) {
+	rc := RuleConfig{
+		ID:     "internal-host",
+		Action: "answer",
+		LocalAnswer: &LocalAnswerConfig{
+			A:   []string{"10.0.0.1", "10.0.0.2"},
+			TTL: 60,
+		},
+	}
+
+	rule, err := convertRuleConfig(rc)
+	if err != nil {
+		t.Fatalf("convertRuleConfig() error = %v", err)
+	}
+	if rule.Action != ActionAnswer {
+		t.Fatalf("Action = %v, want ActionAnswer", rule.Action)
+	}
+	if rule.LocalAnswer == nil || len(rule.LocalAnswer.A) != 2 {
+		t.Fatalf("LocalAnswer.A not parsed correctly: %+v", rule.LocalAnswer)
+	}
+}
+
+func TestConvertRuleConfig_AnswerWithoutRecordsIsError(t *testing.T) {
+	rc := RuleConfig{ID: "bad-answer", Action: "answer"}
+	if _, err := convertRuleConfig(rc); err == nil {
+		t.Fatal("convertRuleConfig() with action=answer and no records should error")
+	}
+}
+
+func TestLocalAnswer_NextA_RoundRobins(t *testing.T) {
+	local := &LocalAnswer{A: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")}}
+
+	got := []string{local.NextA().String(), local.NextA().String(), local.NextA().String()}
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("NextA() sequence = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEngine_LoadRules_AnswerRule(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	const yamlRules = `
+rules:
+  - id: local-host
+    priority: 100
+    enabled: true
+    action: answer
+    domains:
+      - printer.corp.local
+    local_answer:
+      a:
+        - 10.1.1.1
+      ttl: 120
+`
+	if err := os.WriteFile(path, []byte(yamlRules), 0644); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+
+	engine, err := NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	result, err := engine.CheckDomain("printer.corp.local", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionAnswer {
+		t.Fatalf("Action = %v, want ActionAnswer", result.Action)
+	}
+	if result.Rule == nil || result.Rule.LocalAnswer == nil || result.Rule.LocalAnswer.NextA().String() != "10.1.1.1" {
+		t.Fatalf("LocalAnswer not propagated: %+v", result.Rule)
+	}
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// reverseZoneEntry 是由 CIDR 展开出的一条反向解析域名, Wildcard 为 true 时表示
+// 这条记录要以 "*." 前缀插入 (即覆盖该前缀下的所有更深标签), 否则是一个完整地址
+// 的精确匹配 (对应 /32 或 /128)
+type reverseZoneEntry struct {
+	Domain   string
+	Wildcard bool
+}
+
+// ReverseCIDRDomains 把一个 IPv4/IPv6 CIDR 转换为一组 in-addr.arpa/ip6.arpa 域名
+// 字符串 (通配符条目已经带上 "*." 前缀), 使这组域名在 DomainTrie/通配符匹配下
+// 精确覆盖该 CIDR 代表的地址集合。
+//
+// 前缀长度落在字节 (v4) / 半字节 (v6) 边界上时只产生一条记录; 不对齐时 (如
+// 10.0.0.0/10) 在边界所在的那一组标签上按覆盖范围展开成多条记录, 类似经典的
+// 无类 PTR 委派 (RFC 2317), 其余更靠右的标签仍用通配符表示, 因此条目数最多
+// 2^7 (v4) 或 2^3 (v6) 条，不会在跨多组标签上组合爆炸。
+func ReverseCIDRDomains(cidr string) ([]string, error) {
+	entries, err := cidrToReverseZones(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]string, len(entries))
+	for i, e := range entries {
+		if e.Wildcard {
+			domains[i] = "*." + e.Domain
+		} else {
+			domains[i] = e.Domain
+		}
+	}
+	return domains, nil
+}
+
+func cidrToReverseZones(cidr string) ([]reverseZoneEntry, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reverse CIDR %q: %w", cidr, err)
+	}
+
+	var groups []int
+	var groupSize int
+	var suffix string
+
+	if ip4 := ipnet.IP.To4(); ip4 != nil {
+		groupSize = 8
+		suffix = "in-addr.arpa"
+		groups = make([]int, len(ip4))
+		for i, b := range ip4 {
+			groups[i] = int(b)
+		}
+	} else {
+		groupSize = 4
+		suffix = "ip6.arpa"
+		ip6 := ipnet.IP.To16()
+		groups = make([]int, 2*len(ip6))
+		for i, b := range ip6 {
+			groups[2*i] = int(b >> 4)
+			groups[2*i+1] = int(b & 0x0f)
+		}
+	}
+
+	ones, _ := ipnet.Mask.Size()
+	groupCount := len(groups)
+	numFull := ones / groupSize
+	remainder := ones % groupSize
+
+	if remainder == 0 {
+		return []reverseZoneEntry{{
+			Domain:   buildReverseDomain(groups[:numFull], suffix),
+			Wildcard: numFull < groupCount,
+		}}, nil
+	}
+
+	// numFull 组已经完全确定, 第 numFull 组 (0-indexed) 只有高 remainder 位确定,
+	// 低 (groupSize-remainder) 位在该 CIDR 范围内任意取值, 取值范围是连续的一段
+	rangeSize := 1 << (groupSize - remainder)
+	base := groups[numFull]
+	isLastGroup := numFull == groupCount-1
+
+	entries := make([]reverseZoneEntry, 0, rangeSize)
+	for v := base; v < base+rangeSize; v++ {
+		g := append(append([]int{}, groups[:numFull]...), v)
+		entries = append(entries, reverseZoneEntry{
+			Domain:   buildReverseDomain(g, suffix),
+			Wildcard: !isLastGroup,
+		})
+	}
+	return entries, nil
+}
+
+// buildReverseDomain 把 MSB 在前排列的组 (v4 字节或 v6 半字节) 转换成标准 PTR
+// 域名文本形式 (LSB 在前), 例如 v4 的 [10,0] -> "0.10.in-addr.arpa"
+func buildReverseDomain(groups []int, suffix string) string {
+	labels := make([]string, 0, len(groups)+1)
+	for i := len(groups) - 1; i >= 0; i-- {
+		if suffix == "ip6.arpa" {
+			labels = append(labels, strconv.FormatInt(int64(groups[i]), 16))
+		} else {
+			labels = append(labels, strconv.Itoa(groups[i]))
+		}
+	}
+	labels = append(labels, suffix)
+	return strings.Join(labels, ".")
+}
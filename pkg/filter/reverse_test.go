@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"sort"
+	"testing"
+
+	"xdp-dns/pkg/dns"
+)
+
+func TestReverseCIDRDomains_V4OctetAligned(t *testing.T) {
+	got, err := ReverseCIDRDomains("10.0.0.0/24")
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains() error = %v", err)
+	}
+	want := []string{"*.0.0.10.in-addr.arpa"}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("ReverseCIDRDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestReverseCIDRDomains_V4SingleHost(t *testing.T) {
+	got, err := ReverseCIDRDomains("10.0.0.1/32")
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains() error = %v", err)
+	}
+	want := "1.0.0.10.in-addr.arpa"
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("ReverseCIDRDomains() = %v, want [%s]", got, want)
+	}
+}
+
+func TestReverseCIDRDomains_V4NonOctetAligned(t *testing.T) {
+	// 10.0.0.0/10: 第一个字节固定为 10, 第二个字节只有高 2 位确定 (0), 低 6 位
+	// 任意取值, 覆盖 0..63 共 64 个值, 每个值都是一条带通配符的记录
+	got, err := ReverseCIDRDomains("10.0.0.0/10")
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains() error = %v", err)
+	}
+	if len(got) != 64 {
+		t.Fatalf("len(ReverseCIDRDomains()) = %d, want 64", len(got))
+	}
+	sort.Strings(got)
+	if got[0] != "*.0.10.in-addr.arpa" {
+		t.Errorf("first entry = %q, want %q", got[0], "*.0.10.in-addr.arpa")
+	}
+}
+
+func TestReverseCIDRDomains_V4NonOctetAlignedLastGroupIsExact(t *testing.T) {
+	// 10.0.0.0/30: 最后一组 (第四字节) 只有高 6 位确定, 覆盖 4 个具体地址,
+	// 这一组之后没有更多标签了, 所以每条都应该是精确匹配而不是通配符
+	got, err := ReverseCIDRDomains("10.0.0.0/30")
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains() error = %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("len(ReverseCIDRDomains()) = %d, want 4", len(got))
+	}
+	for _, d := range got {
+		if len(d) > 0 && d[0] == '*' {
+			t.Errorf("entry %q should be an exact match, not a wildcard", d)
+		}
+	}
+	sort.Strings(got)
+	if got[0] != "0.0.0.10.in-addr.arpa" {
+		t.Errorf("first entry = %q, want %q", got[0], "0.0.0.10.in-addr.arpa")
+	}
+}
+
+func TestReverseCIDRDomains_V6Aligned(t *testing.T) {
+	got, err := ReverseCIDRDomains("2001:db8::/32")
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains() error = %v", err)
+	}
+	want := "8.b.d.0.1.0.0.2.ip6.arpa"
+	if len(got) != 1 || got[0] != "*."+want {
+		t.Fatalf("ReverseCIDRDomains() = %v, want [*.%s]", got, want)
+	}
+}
+
+func TestReverseCIDRDomains_V6NonNibbleAligned(t *testing.T) {
+	// /34 落在第 9 个半字节中间 (34 = 8*4 + 2), 覆盖该半字节 4 个取值
+	got, err := ReverseCIDRDomains("2001:db8::/34")
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains() error = %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("len(ReverseCIDRDomains()) = %d, want 4", len(got))
+	}
+}
+
+func TestReverseCIDRDomains_InvalidCIDR(t *testing.T) {
+	if _, err := ReverseCIDRDomains("not-a-cidr"); err == nil {
+		t.Fatal("ReverseCIDRDomains() with invalid input should error")
+	}
+}
+
+func TestEngine_CheckDomain_MatchesPTRQueryWithinCIDR(t *testing.T) {
+	engine, err := NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	engine.AddRule(Rule{
+		ID:      "internal-net",
+		Enabled: true,
+		Action:  ActionBlock,
+		Domains: mustReverseDomains(t, "10.0.0.0/24"),
+	})
+
+	result, err := engine.CheckDomain("5.0.0.10.in-addr.arpa", dns.TypePTR, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionBlock {
+		t.Fatalf("Action = %v, want ActionBlock", result.Action)
+	}
+
+	// 不在该 /24 范围内的地址不应该命中
+	result, err = engine.CheckDomain("5.0.1.10.in-addr.arpa", dns.TypePTR, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionAllow {
+		t.Fatalf("Action = %v, want ActionAllow for out-of-range PTR name", result.Action)
+	}
+}
+
+func TestConvertRuleConfig_ReverseCIDRWithLocalPTRAnswer(t *testing.T) {
+	rule, err := convertRuleConfig(RuleConfig{
+		ID:           "reverse-zone",
+		Action:       "answer",
+		ReverseCIDRs: []string{"10.1.1.1/32"},
+		LocalAnswer:  &LocalAnswerConfig{PTR: "host.corp.local", TTL: 60},
+	})
+	if err != nil {
+		t.Fatalf("convertRuleConfig() error = %v", err)
+	}
+	if len(rule.Domains) != 1 || rule.Domains[0] != "1.1.1.10.in-addr.arpa" {
+		t.Fatalf("Domains = %v, want [1.1.1.10.in-addr.arpa]", rule.Domains)
+	}
+	if rule.LocalAnswer == nil || rule.LocalAnswer.PTR != "host.corp.local" {
+		t.Fatalf("LocalAnswer.PTR not propagated: %+v", rule.LocalAnswer)
+	}
+}
+
+func mustReverseDomains(t *testing.T, cidr string) []string {
+	t.Helper()
+	domains, err := ReverseCIDRDomains(cidr)
+	if err != nil {
+		t.Fatalf("ReverseCIDRDomains(%q) error = %v", cidr, err)
+	}
+	return domains
+}
@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xdp-dns/pkg/dns"
+)
+
+func TestConvertRuleConfig_ParsesRewrite(t *testing.T) {
+	rc := RuleConfig{
+		ID:     "rewrite-spf",
+		Action: "rewrite",
+		Rewrite: &RewriteConfig{
+			A:   []RewriteIPConfig{{IP: "1.2.3.4", TTL: 60}},
+			TXT: []RewriteTXTConfig{{Text: "v=spf1 -all", TTL: 300}},
+		},
+	}
+
+	rule, err := convertRuleConfig(rc)
+	if err != nil {
+		t.Fatalf("convertRuleConfig() error = %v", err)
+	}
+	if rule.Action != ActionRewrite {
+		t.Fatalf("Action = %v, want ActionRewrite", rule.Action)
+	}
+	if rule.Rewrite == nil || len(rule.Rewrite.A) != 1 || rule.Rewrite.A[0].IP.String() != "1.2.3.4" {
+		t.Fatalf("Rewrite.A not parsed correctly: %+v", rule.Rewrite)
+	}
+	if len(rule.Rewrite.TXT) != 1 || rule.Rewrite.TXT[0].Text != "v=spf1 -all" {
+		t.Fatalf("Rewrite.TXT not parsed correctly: %+v", rule.Rewrite)
+	}
+}
+
+func TestConvertRuleConfig_RewriteWithoutConfigIsError(t *testing.T) {
+	rc := RuleConfig{ID: "bad-rewrite", Action: "rewrite"}
+	if _, err := convertRuleConfig(rc); err == nil {
+		t.Fatal("convertRuleConfig() with action=rewrite and no rewrite config should error")
+	}
+}
+
+func TestConvertRuleConfig_RewriteInvalidRCode(t *testing.T) {
+	rc := RuleConfig{
+		ID:      "bad-rcode",
+		Action:  "rewrite",
+		Rewrite: &RewriteConfig{RCode: "not-a-real-rcode"},
+	}
+	if _, err := convertRuleConfig(rc); err == nil {
+		t.Fatal("convertRuleConfig() with unknown rcode should error")
+	}
+}
+
+func TestEngine_LoadRules_RewriteRule(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	const yamlRules = `
+rules:
+  - id: rewrite-nxdomain
+    priority: 100
+    enabled: true
+    action: rewrite
+    domains:
+      - blocked-by-policy.example.com
+    rewrite:
+      rcode: nxdomain
+`
+	if err := os.WriteFile(path, []byte(yamlRules), 0644); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+
+	engine, err := NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	result, err := engine.CheckDomain("blocked-by-policy.example.com", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionRewrite {
+		t.Fatalf("Action = %v, want ActionRewrite", result.Action)
+	}
+	if result.Rewrite == nil || result.Rewrite.RCode == nil || *result.Rewrite.RCode != dns.RCodeNXDomain {
+		t.Fatalf("Rewrite.RCode not propagated: %+v", result.Rewrite)
+	}
+}
@@ -0,0 +1,395 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"xdp-dns/pkg/metrics"
+)
+
+// registeredRuleSource 绑定一个 RuleSource 和它的轮询周期, 由 PollRuleSources 消费
+type registeredRuleSource struct {
+	source       RuleSource
+	pollInterval time.Duration
+}
+
+// RuleSource 是可插拔规则来源的统一接口 (hosts 文件、AdGuard/uBlock 域名列表、
+// dnsmasq 配置片段、纯域名列表等)。每次 Load 都应完整重新读取底层存储并返回当次的
+// 完整规则集 —— 不需要处理增量/并发, Engine.ReloadRuleSource 负责在锁外构建好新
+// Trie 后做一次原子替换。
+type RuleSource interface {
+	// Name 标识这个来源 (日志与 Prometheus 标签), 一般就是文件路径
+	Name() string
+	// Load 读取并解析来源, 返回转换好的规则列表
+	Load() ([]Rule, error)
+}
+
+// ruleSourceIDPrefix 给某个来源产出的规则统一打上 ID 前缀: 既保证这些规则 ID 彼此
+// 唯一, 也让 Engine.ReloadRuleSource 在下一轮重载时认得出"这些规则属于这个来源",
+// 从而只替换该来源贡献的部分, 不影响 YAML 规则或其它来源贡献的规则。
+func ruleSourceIDPrefix(sourceName string) string {
+	return "src:" + sourceName + ":"
+}
+
+// 自动生成规则给的默认优先级: 来源产出的 block 规则低于 YAML 里手写规则的常见优先级
+// 区间, exception (放行) 规则则需要高于同来源的 block 规则才能在通配符匹配中胜出
+// (Engine.Check 对通配符规则按 Priority 降序线性扫描, 取第一条匹配)
+const (
+	ruleSourceBlockPriority     = 10
+	ruleSourceExceptionPriority = 20
+)
+
+// HostsFileSource 解析标准 hosts 文件语法: "<ip> <domain...>", 支持一行声明多个
+// 域名和 # 开头的注释。IP 为 0.0.0.0/127.0.0.1 时视为拉黑 (ActionBlock), 其它任何
+// IP 视为把解析结果重定向到该地址 (ActionRedirect), 这是 Pi-hole/AdAway 之类工具
+// 发布黑名单最常见的两种写法。
+type HostsFileSource struct {
+	Path string
+}
+
+func (s *HostsFileSource) Name() string { return s.Path }
+
+func (s *HostsFileSource) Load() ([]Rule, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("filter: open hosts source %s: %w", s.Path, err)
+	}
+	defer f.Close()
+
+	prefix := ruleSourceIDPrefix(s.Name())
+	var rules []Rule
+	seq := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+
+		action := ActionBlock
+		var redirectIP net.IP
+		if !ip.IsUnspecified() && !ip.IsLoopback() {
+			action = ActionRedirect
+			redirectIP = ip
+		}
+
+		seq++
+		rules = append(rules, Rule{
+			ID:         fmt.Sprintf("%s%d", prefix, seq),
+			Priority:   ruleSourceBlockPriority,
+			Enabled:    true,
+			Action:     action,
+			Domains:    append([]string(nil), fields[1:]...),
+			RedirectIP: redirectIP,
+		})
+	}
+	return rules, scanner.Err()
+}
+
+// AdGuardListSource 解析 AdGuard Home/uBlock Origin 风格的过滤列表语法:
+// "! comment" 行和空行被忽略, "||domain^" 拉黑 domain 及其所有子域名,
+// "@@||domain^" 是例外规则 (放行), 优先级高于同一来源的拉黑规则。不认识的其它
+// cosmetic/element-hiding 语法行 (如 "##.ad-banner") 被跳过。
+type AdGuardListSource struct {
+	Path string
+}
+
+func (s *AdGuardListSource) Name() string { return s.Path }
+
+func (s *AdGuardListSource) Load() ([]Rule, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("filter: open AdGuard list source %s: %w", s.Path, err)
+	}
+	defer f.Close()
+
+	prefix := ruleSourceIDPrefix(s.Name())
+	var rules []Rule
+	seq := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		exception := strings.HasPrefix(line, "@@")
+		if exception {
+			line = line[len("@@"):]
+		}
+		if !strings.HasPrefix(line, "||") {
+			continue // cosmetic/element-hiding 规则等, 与 DNS 层过滤无关
+		}
+		line = line[len("||"):]
+		if idx := strings.IndexByte(line, '^'); idx >= 0 {
+			line = line[:idx]
+		} else {
+			continue // 不是 DNS 地址规则 (缺少定界符)
+		}
+		domain := strings.ToLower(strings.TrimSpace(line))
+		if domain == "" {
+			continue
+		}
+
+		action, priority := ActionBlock, ruleSourceBlockPriority
+		if exception {
+			action, priority = ActionAllow, ruleSourceExceptionPriority
+		}
+
+		seq++
+		rules = append(rules, Rule{
+			ID:       fmt.Sprintf("%s%d", prefix, seq),
+			Priority: priority,
+			Enabled:  true,
+			Action:   action,
+			Domains:  []string{"*." + domain},
+		})
+	}
+	return rules, scanner.Err()
+}
+
+// DnsmasqSource 解析 dnsmasq 配置文件里的 address=/dom/ip 指令, 行为与
+// dnsmasq --address 一致: ip 省略或为 0.0.0.0/:: 时该域名及子域名应答 NXDOMAIN
+// (ActionBlock), 否则重定向到给定地址 (ActionRedirect)。单条指令允许 "/" 分隔的
+// 多个域名共享同一个地址。server=/dom/... 这类按域名指定上游的指令 (包括表示
+// "走默认/系统解析器"的 server=/dom/#) 不会被转换成 ActionForward 规则, 而是被跳过
+// 并记录日志, 不会被静默丢弃或被错误地当成某种过滤动作。
+type DnsmasqSource struct {
+	Path string
+}
+
+func (s *DnsmasqSource) Name() string { return s.Path }
+
+func (s *DnsmasqSource) Load() ([]Rule, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("filter: open dnsmasq source %s: %w", s.Path, err)
+	}
+	defer f.Close()
+
+	prefix := ruleSourceIDPrefix(s.Name())
+	var rules []Rule
+	seq := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "address=/"):
+			parts := strings.Split(strings.TrimPrefix(line, "address="), "/")
+			// parts: ["", dom1, dom2, ..., ip] (前导 "/" 产生一个空字符串元素)
+			if len(parts) < 3 {
+				continue
+			}
+			domains := parts[1 : len(parts)-1]
+			ipField := parts[len(parts)-1]
+
+			action := ActionBlock
+			var redirectIP net.IP
+			if ipField != "" {
+				if ip := net.ParseIP(ipField); ip != nil && !ip.IsUnspecified() {
+					action = ActionRedirect
+					redirectIP = ip
+				}
+			}
+
+			seq++
+			rules = append(rules, Rule{
+				ID:         fmt.Sprintf("%s%d", prefix, seq),
+				Priority:   ruleSourceBlockPriority,
+				Enabled:    true,
+				Action:     action,
+				Domains:    append([]string(nil), domains...),
+				RedirectIP: redirectIP,
+			})
+
+		case strings.HasPrefix(line, "server=/"):
+			log.Printf("filter: dnsmasq source %s: skipping unsupported per-domain upstream directive %q (not converted into a forward rule)", s.Name(), line)
+
+		default:
+			// 其它 dnsmasq 指令 (listen-address、cache-size 等) 与规则无关, 忽略
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// DomainListSource 解析最简单的纯域名列表 (每行一个域名, "#"/";" 开头为注释),
+// 常见于各类 rsync 分发的黑名单发布渠道。每个域名被编译成一条覆盖自身及所有子域名
+// 的通配符拉黑规则。
+type DomainListSource struct {
+	Path string
+}
+
+func (s *DomainListSource) Name() string { return s.Path }
+
+func (s *DomainListSource) Load() ([]Rule, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("filter: open domain list source %s: %w", s.Path, err)
+	}
+	defer f.Close()
+
+	prefix := ruleSourceIDPrefix(s.Name())
+	var rules []Rule
+	seq := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		domain := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if domain == "" || strings.HasPrefix(domain, "#") || strings.HasPrefix(domain, ";") {
+			continue
+		}
+
+		seq++
+		rules = append(rules, Rule{
+			ID:       fmt.Sprintf("%s%d", prefix, seq),
+			Priority: ruleSourceBlockPriority,
+			Enabled:  true,
+			Action:   ActionBlock,
+			Domains:  []string{"*." + domain},
+		})
+	}
+	return rules, scanner.Err()
+}
+
+// NewRuleSource 按类型名构造一个 RuleSource, 对应 config.RuleSourceConfig.Type:
+// "hosts"、"adguard"、"dnsmasq"、"domainlist"
+func NewRuleSource(sourceType, path string) (RuleSource, error) {
+	switch strings.ToLower(sourceType) {
+	case "hosts":
+		return &HostsFileSource{Path: path}, nil
+	case "adguard":
+		return &AdGuardListSource{Path: path}, nil
+	case "dnsmasq":
+		return &DnsmasqSource{Path: path}, nil
+	case "domainlist":
+		return &DomainListSource{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("filter: unknown rule source type %q", sourceType)
+	}
+}
+
+// AddRuleSource 注册一个规则来源, 供 PollRuleSources 按 pollInterval 周期性重载;
+// 注册本身不会立即加载, 调用方通常紧接着调用一次 ReloadRuleSource 做首次加载。
+func (e *Engine) AddRuleSource(source RuleSource, pollInterval time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ruleSources = append(e.ruleSources, registeredRuleSource{source: source, pollInterval: pollInterval})
+}
+
+// PollRuleSources 阻塞地按各自的 pollInterval 周期性重载已注册的规则来源, 直到
+// ctx 结束。每个来源独立计时, 互不影响; 每个来源在进入轮询循环前都会先做一次同步
+// 的初次加载 (呼应 AddRuleSource 的文档: "注册本身不会立即加载"), 否则要等到第一个
+// pollInterval 过去之前这个来源贡献的规则一条都不会生效。
+func (e *Engine) PollRuleSources(ctx context.Context) {
+	e.mu.RLock()
+	sources := append([]registeredRuleSource(nil), e.ruleSources...)
+	e.mu.RUnlock()
+
+	if len(sources) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, rs := range sources {
+		wg.Add(1)
+		go func(rs registeredRuleSource) {
+			defer wg.Done()
+			if err := e.ReloadRuleSource(rs.source); err != nil {
+				log.Printf("filter: rule source %s initial load failed: %v", rs.source.Name(), err)
+			}
+
+			ticker := time.NewTicker(rs.pollInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := e.ReloadRuleSource(rs.source); err != nil {
+						log.Printf("filter: rule source %s reload failed, keeping previous rules: %v", rs.source.Name(), err)
+					}
+				}
+			}
+		}(rs)
+	}
+	wg.Wait()
+}
+
+// ReloadRuleSource 重新读取单个来源, 用它产出的规则替换掉上一轮由该来源贡献的规则
+// (通过 ID 前缀识别), 和其余规则 (YAML 规则 + 其它来源贡献的规则) 合并后在锁外
+// 重建一棵全新的 Trie/Aho-Corasick 自动机, 全部构建完成后才原子生效; 解析失败时
+// 保留此前已生效的规则集不变。
+func (e *Engine) ReloadRuleSource(source RuleSource) error {
+	newRules, err := source.Load()
+	if err != nil {
+		metrics.ObserveRuleSourceReload(source.Name(), 0, err)
+		return err
+	}
+
+	prefix := ruleSourceIDPrefix(source.Name())
+
+	e.mu.RLock()
+	merged := make([]Rule, 0, len(e.rules)+len(newRules))
+	for _, r := range e.rules {
+		if !strings.HasPrefix(r.ID, prefix) {
+			merged = append(merged, r)
+		}
+	}
+	e.mu.RUnlock()
+	merged = append(merged, newRules...)
+
+	// Trie 的精确匹配没有优先级概念, 只有"最后一次 Insert 生效" (见 DomainTrie.Insert),
+	// 所以构建顺序按优先级升序, 让高优先级规则 (如放行例外) 的 Insert 发生在后面从而
+	// 覆盖同名的低优先级规则；e.rules 本身仍保持降序, 供通配符/关键词的线性扫描使用。
+	buildOrder := append([]Rule(nil), merged...)
+	sort.SliceStable(buildOrder, func(i, j int) bool { return buildOrder[i].Priority < buildOrder[j].Priority })
+
+	newTrie := NewDomainTrie()
+	newACMatcher := NewACMatcher()
+	for i := range buildOrder {
+		rule := &buildOrder[i]
+		for _, domain := range rule.Domains {
+			if !strings.HasPrefix(domain, "*") {
+				newTrie.Insert(domain, rule)
+			}
+		}
+		for _, keyword := range rule.Keywords {
+			newACMatcher.AddPattern(keyword, rule.ID)
+		}
+	}
+	newACMatcher.Build()
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Priority > merged[j].Priority })
+
+	e.mu.Lock()
+	e.rules = merged
+	e.mu.Unlock()
+	e.domainTrie.Store(newTrie)
+	e.acMatcher.Store(newACMatcher)
+
+	metrics.ObserveRuleSourceReload(source.Name(), len(newRules), nil)
+	return nil
+}
@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"xdp-dns/pkg/dns"
+)
+
+func writeSourceFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestHostsFileSource_Load(t *testing.T) {
+	path := writeSourceFile(t, "hosts.txt", `
+# comment
+0.0.0.0 ads.example.com
+127.0.0.1 tracker.example.com
+10.0.0.5 intranet.example.com
+`)
+
+	rules, err := (&HostsFileSource{Path: path}).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+	if rules[0].Action != ActionBlock || rules[0].Domains[0] != "ads.example.com" {
+		t.Errorf("rules[0] = %+v, want ActionBlock for ads.example.com", rules[0])
+	}
+	if rules[1].Action != ActionBlock {
+		t.Errorf("rules[1].Action = %v, want ActionBlock for loopback IP", rules[1].Action)
+	}
+	if rules[2].Action != ActionRedirect || rules[2].RedirectIP.String() != "10.0.0.5" {
+		t.Errorf("rules[2] = %+v, want ActionRedirect to 10.0.0.5", rules[2])
+	}
+}
+
+func TestAdGuardListSource_Load_BlockAndException(t *testing.T) {
+	path := writeSourceFile(t, "adguard.txt", `
+! Title: test list
+||ads.example.com^
+@@||safe.ads.example.com^
+##.cosmetic-rule-ignored
+`)
+
+	rules, err := (&AdGuardListSource{Path: path}).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if rules[0].Action != ActionBlock || rules[0].Domains[0] != "*.ads.example.com" {
+		t.Errorf("rules[0] = %+v, want ActionBlock wildcard for ads.example.com", rules[0])
+	}
+	if rules[1].Action != ActionAllow || rules[1].Priority <= rules[0].Priority {
+		t.Errorf("rules[1] = %+v, want ActionAllow with higher priority than the block rule", rules[1])
+	}
+}
+
+func TestDnsmasqSource_Load(t *testing.T) {
+	path := writeSourceFile(t, "dnsmasq.conf", `
+address=/ads.example.com/0.0.0.0
+address=/intranet.example.com/10.0.0.5
+server=/corp.local/#
+`)
+
+	rules, err := (&DnsmasqSource{Path: path}).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2 (server=/.../# should be skipped)", len(rules))
+	}
+	if rules[0].Action != ActionBlock {
+		t.Errorf("rules[0].Action = %v, want ActionBlock", rules[0].Action)
+	}
+	if rules[1].Action != ActionRedirect || rules[1].RedirectIP.String() != "10.0.0.5" {
+		t.Errorf("rules[1] = %+v, want ActionRedirect to 10.0.0.5", rules[1])
+	}
+}
+
+func TestDomainListSource_Load(t *testing.T) {
+	path := writeSourceFile(t, "domains.txt", `
+# comment
+ads.example.com
+tracker.example.com
+`)
+
+	rules, err := (&DomainListSource{Path: path}).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if rules[0].Domains[0] != "*.ads.example.com" || rules[0].Action != ActionBlock {
+		t.Errorf("rules[0] = %+v, want wildcard ActionBlock for ads.example.com", rules[0])
+	}
+}
+
+func TestEngine_ReloadRuleSource_MergesAndReplacesOnNextReload(t *testing.T) {
+	engine, err := NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	engine.AddRule(Rule{ID: "manual-allow", Enabled: true, Action: ActionAllow, Domains: []string{"manual.example.com"}})
+
+	path := writeSourceFile(t, "hosts.txt", "0.0.0.0 ads.example.com\n")
+	source := &HostsFileSource{Path: path}
+
+	if err := engine.ReloadRuleSource(source); err != nil {
+		t.Fatalf("ReloadRuleSource() error = %v", err)
+	}
+
+	result, err := engine.CheckDomain("ads.example.com", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionBlock {
+		t.Fatalf("Action = %v, want ActionBlock after first reload", result.Action)
+	}
+
+	// manual rule added via AddRule before the source existed must survive the merge
+	result, err = engine.CheckDomain("manual.example.com", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionAllow {
+		t.Fatalf("Action = %v, want ActionAllow for the manually added rule", result.Action)
+	}
+
+	// rewrite the source file, dropping ads.example.com; a second reload must retract it
+	if err := os.WriteFile(path, []byte("10.0.0.9 newsite.example.com\n"), 0644); err != nil {
+		t.Fatalf("rewrite source file: %v", err)
+	}
+	if err := engine.ReloadRuleSource(source); err != nil {
+		t.Fatalf("second ReloadRuleSource() error = %v", err)
+	}
+
+	result, err = engine.CheckDomain("ads.example.com", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionAllow {
+		t.Fatalf("Action = %v, want ActionAllow once the source no longer lists ads.example.com", result.Action)
+	}
+
+	result, err = engine.CheckDomain("newsite.example.com", dns.TypeA, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != ActionRedirect {
+		t.Fatalf("Action = %v, want ActionRedirect for the new entry", result.Action)
+	}
+}
+
+func TestEngine_PollRuleSources_LoadsImmediatelyBeforeFirstTick(t *testing.T) {
+	engine, err := NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	path := writeSourceFile(t, "hosts.txt", "0.0.0.0 ads.example.com\n")
+	source := &HostsFileSource{Path: path}
+	// pollInterval is intentionally much longer than the test timeout below: if
+	// PollRuleSources only loaded on ticks, this rule would never appear in time.
+	engine.AddRuleSource(source, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		engine.PollRuleSources(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		result, err := engine.CheckDomain("ads.example.com", dns.TypeA, nil)
+		if err != nil {
+			t.Fatalf("CheckDomain() error = %v", err)
+		}
+		if result.Action == ActionBlock {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rule source was not loaded immediately by PollRuleSources")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestNewRuleSource_UnknownTypeIsError(t *testing.T) {
+	if _, err := NewRuleSource("bogus", "/tmp/x"); err == nil {
+		t.Fatal("NewRuleSource() with an unknown type should error")
+	}
+}
@@ -1,6 +1,12 @@
 package filter
 
-import "net"
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"xdp-dns/pkg/dns"
+)
 
 // Action 过滤动作
 type Action int
@@ -10,6 +16,10 @@ const (
 	ActionBlock                  // 阻止
 	ActionRedirect               // 重定向
 	ActionLog                    // 仅记录日志
+	ActionRewrite                // $dnsrewrite 风格的应答合成, 详见 Rewrite
+	ActionFakeDNS                // 从 fakedns 地址池即时分配 A/AAAA 应答, 详见 pkg/fakedns
+	ActionForward                // 转发给一组上游解析器并发竞速, 详见 Rule.Forwarders 和 pkg/forwarder
+	ActionAnswer                 // 从本地权威记录集直接应答, 不经过任何上游, 详见 Rule.LocalAnswer
 )
 
 // String 返回动作名称
@@ -23,6 +33,14 @@ func (a Action) String() string {
 		return "redirect"
 	case ActionLog:
 		return "log"
+	case ActionRewrite:
+		return "rewrite"
+	case ActionFakeDNS:
+		return "fakedns"
+	case ActionForward:
+		return "forward"
+	case ActionAnswer:
+		return "answer"
 	default:
 		return "unknown"
 	}
@@ -35,30 +53,245 @@ type Rule struct {
 	Enabled     bool     `yaml:"enabled"`      // 是否启用
 	Action      Action   `yaml:"action"`       // 动作
 	Domains     []string `yaml:"domains"`      // 域名匹配列表 (支持通配符)
+	Keywords    []string `yaml:"keywords"`     // 关键词匹配列表 (查询域名中任意位置的子串, 经 Aho-Corasick 匹配)
 	QueryTypes  []uint16 `yaml:"query_types"`  // 查询类型过滤
 	RedirectIP  net.IP   `yaml:"redirect_ip"`  // 重定向IP
 	RedirectTTL uint32   `yaml:"redirect_ttl"` // 重定向TTL
+	Rewrite     *Rewrite `yaml:"rewrite"`      // ActionRewrite 专用的应答合成规格, 其它动作下为 nil
 	Description string   `yaml:"description"`  // 规则描述
+
+	// ClientSubnets 限定规则只对落在这些 CIDR 内的客户端地址生效, 为空表示不限制。
+	// 匹配时优先使用查询所带 EDNS Client Subnet (ECS) 选项中的地址, 没有 ECS 时
+	// 退回直接来源 IP, 详见 CheckContext。
+	ClientSubnets []*net.IPNet `yaml:"-"`
+
+	// StripECS/OverwriteECS 控制转发给上游前如何处理 ECS 选项: StripECS 为 true 时
+	// 整条选项剥离, OverwriteECS 非 nil 时把地址覆盖为该子网代表地址。两者目前只是
+	// 声明: ActionForward 的转发路径 (pkg/forwarder) 把查询报文原样发给上游, 不读取
+	// 这两个字段。
+	StripECS     bool       `yaml:"strip_ecs"`
+	OverwriteECS *net.IPNet `yaml:"-"`
+
+	// Forwarders 是 ActionForward 专用的上游解析器列表, 其它动作下为空。Engine 只负责
+	// 把匹配规则的 Forwarders 透出给调用方 (见 CheckResult.Rule), 真正的并发竞速转发由
+	// pkg/forwarder 实现。
+	Forwarders []Upstream `yaml:"-"`
+
+	// LocalAnswer 是 ActionAnswer 专用的本地权威记录集, 其它动作下为 nil
+	LocalAnswer *LocalAnswer `yaml:"-"`
+}
+
+// Upstream 描述 ActionForward 规则的一个候选上游解析器
+type Upstream struct {
+	Addr       string        // 上游地址, "ip:port" 形式
+	StartDelay time.Duration // 相对第一个上游的起跑延迟, 用于 pkg/forwarder 的竞速调度, 零值表示不延迟
+}
+
+// LocalAnswer 是 ActionAnswer 规则的本地小型权威区域, 形态上对应 Tailscale
+// Config.Hosts map[FQDN][]netip.Addr: 一组常驻内存的 A/AAAA 记录, 匹配后直接从
+// 这里应答, 不联系任何上游。A/AAAA 各自可以配置多条记录, 由 NextA/NextAAAA 按
+// 请求轮询 (round-robin) 各取一条; 查询类型没有对应记录时应答 NODATA。
+type LocalAnswer struct {
+	A    []net.IP
+	AAAA []net.IP
+	// PTR 是反向解析 (PTR 查询) 专用的主机名, 通常与规则上配置的 ReverseCIDRs
+	// 搭配使用; 为空表示这条规则没有配置反向应答
+	PTR string
+	TTL uint32
+
+	rrA    atomic.Uint64
+	rrAAAA atomic.Uint64
+}
+
+// NextA 轮询返回下一条 A 记录, A 为空时返回 nil
+func (l *LocalAnswer) NextA() net.IP {
+	if len(l.A) == 0 {
+		return nil
+	}
+	idx := l.rrA.Add(1) - 1
+	return l.A[idx%uint64(len(l.A))]
+}
+
+// NextAAAA 轮询返回下一条 AAAA 记录, AAAA 为空时返回 nil
+func (l *LocalAnswer) NextAAAA() net.IP {
+	if len(l.AAAA) == 0 {
+		return nil
+	}
+	idx := l.rrAAAA.Add(1) - 1
+	return l.AAAA[idx%uint64(len(l.AAAA))]
+}
+
+// Rewrite 描述一条 $dnsrewrite 风格 (ActionRewrite) 的应答合成规格, 比 RedirectIP
+// 表达力更强: 可以强制响应码/NODATA, 或者合成任意数量的 A/AAAA/MX/TXT/PTR/SRV/
+// HTTPS/SVCB 答案, 也可以把查询重写到另一个 CNAME 目标。一条规则允许同时配置 A
+// 和 AAAA, Engine 按查询类型挑选其中一套应答。
+type Rewrite struct {
+	RCode  *uint16 // 强制响应码 (NOERROR/NXDOMAIN/REFUSED/SERVFAIL), nil 表示不强制
+	NoData bool    // 强制 NODATA (NOERROR 且不带 Answer), 优先级高于 RCode 和下面的合成记录
+	CNAME  string  // 重写为另一个域名的 CNAME; worker 只合成这条 CNAME 记录, 不跟随解析目标域名
+
+	A     []RewriteA
+	AAAA  []RewriteAAAA
+	MX    []RewriteMX
+	TXT   []RewriteTXT
+	PTR   []RewritePTR
+	SRV   []RewriteSRV
+	HTTPS []RewriteSVCB // HTTPS (type 65), 与 SVCB 共享同一 RDATA 结构
+	SVCB  []RewriteSVCB
+}
+
+// RewriteA/RewriteAAAA 合成的 A/AAAA 记录, 每条记录可以携带独立的 TTL
+type RewriteA struct {
+	IP  net.IP
+	TTL uint32
+}
+
+type RewriteAAAA struct {
+	IP  net.IP
+	TTL uint32
+}
+
+// RewriteMX 合成的 MX 记录
+type RewriteMX struct {
+	Preference uint16
+	Exchange   string
+	TTL        uint32
+}
+
+// RewriteTXT 合成的 TXT 记录
+type RewriteTXT struct {
+	Text string
+	TTL  uint32
+}
+
+// RewritePTR 合成的 PTR 记录
+type RewritePTR struct {
+	Target string
+	TTL    uint32
+}
+
+// RewriteSRV 合成的 SRV 记录
+type RewriteSRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+	TTL      uint32
+}
+
+// RewriteSVCB 合成的 SVCB/HTTPS (RFC 9460) 记录; 简化实现, 不支持 SvcParams
+// (alpn/port 等), 仓库里目前没有需要这些参数的场景
+type RewriteSVCB struct {
+	Priority uint16
+	Target   string
+	TTL      uint32
 }
 
 // RuleSet 规则集配置
 type RuleSet struct {
-	Rules       []RuleConfig      `yaml:"rules"`        // 规则列表
-	IPBlacklist []string          `yaml:"ip_blacklist"` // IP黑名单
-	RateLimits  []RateLimitConfig `yaml:"rate_limits"`  // 速率限制
+	Rules       []RuleConfig       `yaml:"rules"`        // 规则列表
+	IPBlacklist []string           `yaml:"ip_blacklist"` // IP黑名单
+	RateLimits  []RateLimitConfig  `yaml:"rate_limits"`  // 速率限制
+	LogSampling map[string]float64 `yaml:"log_sampling"` // 按动作采样导出到 Sink 的比例 (0.0~1.0), 键为动作名 (allow/block/redirect/log)
 }
 
 // RuleConfig YAML规则配置
 type RuleConfig struct {
-	ID          string   `yaml:"id"`
-	Priority    int      `yaml:"priority"`
-	Enabled     bool     `yaml:"enabled"`
-	Action      string   `yaml:"action"`
-	Domains     []string `yaml:"domains"`
-	QueryTypes  []string `yaml:"query_types"`
-	RedirectIP  string   `yaml:"redirect_ip"`
-	RedirectTTL uint32   `yaml:"redirect_ttl"`
-	Description string   `yaml:"description"`
+	ID          string         `yaml:"id"`
+	Priority    int            `yaml:"priority"`
+	Enabled     bool           `yaml:"enabled"`
+	Action      string         `yaml:"action"`
+	Domains     []string       `yaml:"domains"`
+	Keywords    []string       `yaml:"keywords"`
+	QueryTypes  []string       `yaml:"query_types"`
+	RedirectIP  string         `yaml:"redirect_ip"`
+	RedirectTTL uint32         `yaml:"redirect_ttl"`
+	Rewrite     *RewriteConfig `yaml:"rewrite"`
+	Description string         `yaml:"description"`
+
+	ClientSubnets []string `yaml:"client_subnets"` // 客户端子网 CIDR 列表, 如 10.0.0.0/8、2001:db8::/32
+	StripECS      bool     `yaml:"strip_ecs"`
+	OverwriteECS  string   `yaml:"overwrite_ecs"`
+
+	// ReverseCIDRs 是一组 IPv4/IPv6 CIDR (如 "10.0.0.0/8"、"2001:db8::/32"), 会被
+	// 转换成对应的 in-addr.arpa/ip6.arpa 域名并入 Domains, 使这条规则同时能匹配
+	// 落在这些 CIDR 内地址的 PTR 查询, 见 ReverseCIDRDomains
+	ReverseCIDRs []string `yaml:"reverse_cidrs"`
+
+	Forwarders []UpstreamConfig `yaml:"forwarders"` // action: forward 专用, 至少需要一个上游
+
+	LocalAnswer *LocalAnswerConfig `yaml:"local_answer"` // action: answer 专用
+}
+
+// UpstreamConfig YAML 中单个上游解析器配置, 对应 Upstream
+type UpstreamConfig struct {
+	Addr       string        `yaml:"addr"`        // "ip:port" 形式
+	StartDelay time.Duration `yaml:"start_delay"` // 起跑延迟, 如 "20ms"
+}
+
+// LocalAnswerConfig YAML 中 action: answer 规则的本地记录配置, 对应 LocalAnswer
+type LocalAnswerConfig struct {
+	A    []string `yaml:"a"`
+	AAAA []string `yaml:"aaaa"`
+	PTR  string   `yaml:"ptr"`
+	TTL  uint32   `yaml:"ttl"`
+}
+
+// RewriteConfig YAML 中 action: rewrite 规则的应答合成配置, 对应 Rewrite
+type RewriteConfig struct {
+	RCode  string `yaml:"rcode"`  // noerror/nxdomain/refused/servfail, 为空表示不强制
+	NoData bool   `yaml:"nodata"` // 强制 NODATA
+	CNAME  string `yaml:"cname"`
+
+	A     []RewriteIPConfig   `yaml:"a"`
+	AAAA  []RewriteIPConfig   `yaml:"aaaa"`
+	MX    []RewriteMXConfig   `yaml:"mx"`
+	TXT   []RewriteTXTConfig  `yaml:"txt"`
+	PTR   []RewritePTRConfig  `yaml:"ptr"`
+	SRV   []RewriteSRVConfig  `yaml:"srv"`
+	HTTPS []RewriteSVCBConfig `yaml:"https"`
+	SVCB  []RewriteSVCBConfig `yaml:"svcb"`
+}
+
+// RewriteIPConfig 单条合成的 A/AAAA 记录
+type RewriteIPConfig struct {
+	IP  string `yaml:"ip"`
+	TTL uint32 `yaml:"ttl"`
+}
+
+// RewriteMXConfig 单条合成的 MX 记录
+type RewriteMXConfig struct {
+	Preference uint16 `yaml:"preference"`
+	Exchange   string `yaml:"exchange"`
+	TTL        uint32 `yaml:"ttl"`
+}
+
+// RewriteTXTConfig 单条合成的 TXT 记录
+type RewriteTXTConfig struct {
+	Text string `yaml:"text"`
+	TTL  uint32 `yaml:"ttl"`
+}
+
+// RewritePTRConfig 单条合成的 PTR 记录
+type RewritePTRConfig struct {
+	Target string `yaml:"target"`
+	TTL    uint32 `yaml:"ttl"`
+}
+
+// RewriteSRVConfig 单条合成的 SRV 记录
+type RewriteSRVConfig struct {
+	Priority uint16 `yaml:"priority"`
+	Weight   uint16 `yaml:"weight"`
+	Port     uint16 `yaml:"port"`
+	Target   string `yaml:"target"`
+	TTL      uint32 `yaml:"ttl"`
+}
+
+// RewriteSVCBConfig 单条合成的 SVCB/HTTPS 记录
+type RewriteSVCBConfig struct {
+	Priority uint16 `yaml:"priority"`
+	Target   string `yaml:"target"`
+	TTL      uint32 `yaml:"ttl"`
 }
 
 // RateLimitConfig 速率限制配置
@@ -83,6 +316,43 @@ type CheckResult struct {
 	Rule        *Rule
 	RuleID      string
 	MatchedName string
-	RedirectIP  []byte // IPv4 或 IPv6
+	RedirectIP  []byte   // IPv4 或 IPv6
 	TTL         uint32
+	Rewrite     *Rewrite // Action 为 ActionRewrite 时生效, 其它动作下为 nil
+	RawQuery    []byte   // 原始查询报文; CheckDomain 只拿到域名和类型, 不填充此字段, Sink 导出的报文来自 Decision.RawQuery
+
+	StripECS     bool       // 转发前是否剥离 ECS 选项, 见 Rule.StripECS
+	OverwriteECS *net.IPNet // 转发前覆盖 ECS 地址为该子网, 见 Rule.OverwriteECS
+}
+
+// CheckContext 提供 Check/CheckDomain 在域名/类型之外的额外匹配上下文, 目前只用于
+// Rule.ClientSubnets 匹配: ClientIP 优先取自查询的 EDNS Client Subnet (ECS) 选项
+// (递归解析器代为查询时声明的真实客户端子网), 没有 ECS 时退回直接来源 IP, 没有任何
+// 地址信息时为 nil (此时带 ClientSubnets 的规则永远不匹配)。
+type CheckContext struct {
+	ClientIP net.IP
+}
+
+// NewCheckContext 根据来源 IP 和 (可能为 nil 的) EDNS 信息构建 CheckContext
+func NewCheckContext(srcIP string, edns *dns.EDNS) *CheckContext {
+	if ecs := edns.ECS(); ecs != nil && ecs.Address != nil {
+		return &CheckContext{ClientIP: ecs.Address}
+	}
+	return &CheckContext{ClientIP: net.ParseIP(srcIP)}
+}
+
+// Decision 一次过滤决策的快照, 在 Engine.Check/CheckDomain 完成匹配后传给 Sink
+type Decision struct {
+	SrcIP    string
+	Domain   string
+	QType    uint16
+	Action   Action
+	RuleID   string
+	RawQuery []byte
+}
+
+// Sink 是过滤决策的导出接口, 由 dnstap 等日志/观测子系统实现
+// Engine 对每次 Check/CheckDomain 决策按 log_sampling 的比例采样后推给已注册的 Sink (如果有)
+type Sink interface {
+	OnDecision(d Decision)
 }
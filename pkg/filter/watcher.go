@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"xdp-dns/pkg/metrics"
+)
+
+// Watcher 监听规则文件所在目录, 文件发生变化时触发 Engine.LoadRules 完成热重载
+//
+// 监听目录而不是文件本身, 是因为很多编辑器/配置管理工具 (如 vim、ConfigMap 挂载)
+// 通过"写临时文件再 rename 替换"的方式更新文件, 直接对文件描述符的 inotify watch
+// 会在 rename 后失效；监听父目录并在事件里过滤文件名可以兼顾两种更新方式。
+type Watcher struct {
+	engine *Engine
+	path   string
+	fsw    *fsnotify.Watcher
+}
+
+// NewWatcher 创建一个监听 path 所在目录的 Watcher
+func NewWatcher(engine *Engine, path string) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("filter: create fsnotify watcher: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := fsw.Add(dir); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("filter: watch directory %s: %w", dir, err)
+	}
+
+	return &Watcher{engine: engine, path: path, fsw: fsw}, nil
+}
+
+// Run 阻塞处理文件系统事件, 直到 ctx 结束
+func (w *Watcher) Run(ctx context.Context) {
+	defer w.fsw.Close()
+
+	target := filepath.Clean(w.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != target {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			w.reload()
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("filter: watcher error: %v", err)
+		}
+	}
+}
+
+// reload 执行一次热重载, 失败时保留此前已生效的规则集并只记录日志 (回滚由 Engine.LoadRules 保证)
+func (w *Watcher) reload() {
+	before := w.engine.Snapshot()
+	start := time.Now()
+
+	if err := w.engine.LoadRules(w.path); err != nil {
+		log.Printf("filter: hot reload of %s failed, keeping previous ruleset: %v", w.path, err)
+		return
+	}
+
+	after := w.engine.Snapshot()
+	duration := time.Since(start)
+	diff := before.Diff(after)
+	delta := len(after.Rules) - len(before.Rules)
+
+	metrics.ObserveRuleReload(duration, delta)
+	log.Printf("filter: reloaded %s in %s (added=%d removed=%d modified=%d)",
+		w.path, duration, len(diff.Added), len(diff.Removed), len(diff.Modified))
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const baseRules = `
+rules:
+  - id: block-ads
+    priority: 100
+    enabled: true
+    action: block
+    domains:
+      - ads.example.com
+`
+
+const updatedRules = `
+rules:
+  - id: block-ads
+    priority: 100
+    enabled: true
+    action: block
+    domains:
+      - ads.example.com
+  - id: block-tracker
+    priority: 100
+    enabled: true
+    action: block
+    domains:
+      - tracker.example.com
+`
+
+const invalidRules = `
+rules:
+  - id: bad-rule
+    priority: 100
+    enabled: true
+    action: not-a-real-action
+    domains:
+      - bad.example.com
+`
+
+func writeRulesFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+}
+
+func TestEngine_LoadRulesRejectsUnknownAction(t *testing.T) {
+	engine, err := NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	engine.AddRule(Rule{ID: "keep-me", Enabled: true, Action: ActionBlock, Domains: []string{"keep.example.com"}})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	writeRulesFile(t, path, invalidRules)
+
+	if err := engine.LoadRules(path); err == nil {
+		t.Fatal("LoadRules() with unknown action should return an error")
+	}
+
+	// Rollback: the previously loaded rule must still be in effect.
+	if _, ok := engine.GetRule("keep-me"); !ok {
+		t.Error("LoadRules() failure should not discard the previously active ruleset")
+	}
+}
+
+func TestWatcher_ReloadsOnFileWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	writeRulesFile(t, path, baseRules)
+
+	engine, err := NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	if len(engine.GetRules()) != 1 {
+		t.Fatalf("initial load: got %d rules, want 1", len(engine.GetRules()))
+	}
+
+	watcher, err := NewWatcher(engine, path)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Run(ctx)
+
+	writeRulesFile(t, path, updatedRules)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(engine.GetRules()) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got := len(engine.GetRules()); got != 2 {
+		t.Fatalf("after hot reload: got %d rules, want 2", got)
+	}
+	if _, ok := engine.GetRule("block-tracker"); !ok {
+		t.Error("expected newly added rule block-tracker to be present after reload")
+	}
+}
@@ -0,0 +1,197 @@
+// Package forwarder 实现 ActionForward 规则的上游转发: 向一组候选上游解析器并发
+// 发起 UDP 查询, 按各自配置的起跑延迟错开发起时间, 采用第一个通过校验的应答, 其余
+// 请求通过 context 取消放弃 (不等待、不重试) —— 与 Tailscale resolverAndDelay 的
+// 竞速思路一致。
+package forwarder
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/metrics"
+)
+
+// Upstream 描述一个候选上游解析器, 与 filter.Upstream 形状相同但独立定义,
+// 避免 pkg/forwarder 反向依赖 pkg/filter (仿照 convertRateLimits 的跨包约定)。
+type Upstream struct {
+	Addr       string        // 上游地址, "ip:port" 形式
+	StartDelay time.Duration // 相对第一个上游的起跑延迟
+}
+
+// DefaultTimeout 是单次查询的默认超时, 超过后 Forward 放弃所有仍在途的上游
+const DefaultTimeout = 2 * time.Second
+
+var (
+	// ErrNoUpstreams 在调用 Forward 时没有配置任何上游
+	ErrNoUpstreams = errors.New("forwarder: no upstreams configured")
+	// ErrAllFailed 是所有上游都未能在超时内返回合格应答时的汇总错误
+	ErrAllFailed = errors.New("forwarder: all upstreams failed")
+)
+
+// Forwarder 持有一组候选上游, 对外提供 Forward 完成一次竞速转发
+type Forwarder struct {
+	upstreams []Upstream
+	timeout   time.Duration
+	dialer    net.Dialer
+}
+
+// New 创建一个按 upstreams 列表竞速转发的 Forwarder, timeout<=0 时使用 DefaultTimeout
+func New(upstreams []Upstream, timeout time.Duration) *Forwarder {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Forwarder{upstreams: upstreams, timeout: timeout}
+}
+
+// raceResult 是单个上游查询的结果, 通过 channel 汇报给 race 的主循环
+type raceResult struct {
+	upstream string
+	response []byte
+	err      error
+}
+
+// Forward 转发一条原始 DNS 查询报文, 返回第一个通过校验的上游应答原始字节
+// (事务 ID 已校验与查询一致, 可直接回传给客户端), 或者在所有上游都失败/超时后返回错误。
+func (f *Forwarder) Forward(ctx context.Context, query []byte) ([]byte, error) {
+	if len(f.upstreams) == 0 {
+		return nil, ErrNoUpstreams
+	}
+
+	parser := dns.NewParser()
+	queryMsg, err := parser.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("forwarder: parse outgoing query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	results := make(chan raceResult, len(f.upstreams))
+	for _, up := range f.upstreams {
+		go f.race(ctx, up, query, queryMsg, results)
+	}
+
+	var lastErr error
+	for range f.upstreams {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				lastErr = res.err
+				continue
+			}
+			return res.response, nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrAllFailed, ctx.Err())
+		}
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAllFailed, lastErr)
+	}
+	return nil, ErrAllFailed
+}
+
+// race 等待 up.StartDelay 后向该上游发起一次查询, 把结果 (含错误) 写入 results
+func (f *Forwarder) race(ctx context.Context, up Upstream, query []byte, queryMsg *dns.Message, results chan<- raceResult) {
+	if up.StartDelay > 0 {
+		timer := time.NewTimer(up.StartDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			results <- raceResult{upstream: up.Addr, err: ctx.Err()}
+			return
+		}
+	}
+
+	start := time.Now()
+	response, err := f.query(ctx, up.Addr, query, queryMsg)
+	metrics.ObserveForwarderAttempt(up.Addr, time.Since(start), err)
+	results <- raceResult{upstream: up.Addr, response: response, err: err}
+}
+
+// query 向单个上游发起一次 UDP 查询并校验应答
+func (f *Forwarder) query(ctx context.Context, addr string, query []byte, queryMsg *dns.Message) ([]byte, error) {
+	conn, err := f.dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("forwarder: dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("forwarder: write to %s: %w", addr, err)
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("forwarder: read from %s: %w", addr, err)
+	}
+	response := buf[:n]
+
+	if err := validateResponse(queryMsg, response); err != nil {
+		return nil, fmt.Errorf("forwarder: invalid response from %s: %w", addr, err)
+	}
+	return response, nil
+}
+
+// validateResponse 校验上游应答是否可以原样回传给客户端: 事务 ID 必须和查询一致,
+// 不能是截断应答 (TC=1, RFC 1035 §4.1.1 flags 中的 0x0200 位), 问题部分必须和查询
+// 完全匹配 (域名忽略大小写、类型、类均一致), 防止缓存投毒或乱序应答被当作正确结果。
+func validateResponse(queryMsg *dns.Message, raw []byte) error {
+	if len(raw) < 12 {
+		return dns.ErrTooShort
+	}
+	if binary.BigEndian.Uint16(raw[0:2]) != queryMsg.Header.ID {
+		return fmt.Errorf("transaction id mismatch")
+	}
+	flags := binary.BigEndian.Uint16(raw[2:4])
+	if flags&0x0200 != 0 {
+		return fmt.Errorf("truncated response (TC bit set)")
+	}
+
+	parser := dns.NewParser()
+	respMsg, err := parser.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if len(respMsg.Questions) != len(queryMsg.Questions) {
+		return fmt.Errorf("question count mismatch")
+	}
+	for i, q := range queryMsg.Questions {
+		rq := respMsg.Questions[i]
+		if !equalFoldName(rq.Name, q.Name) || rq.QType != q.QType || rq.QClass != q.QClass {
+			return fmt.Errorf("question section mismatch")
+		}
+	}
+	return nil
+}
+
+// equalFoldName 按 DNS 域名比较惯例忽略大小写
+func equalFoldName(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
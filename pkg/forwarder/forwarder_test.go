@@ -0,0 +1,181 @@
+package forwarder
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// buildTestQuery 构造一个指向单条 A 查询的最小 DNS 查询报文
+func buildTestQuery(id uint16, name string) []byte {
+	msg := make([]byte, 0, 32)
+	var header [12]byte
+	binary.BigEndian.PutUint16(header[0:2], id)
+	binary.BigEndian.PutUint16(header[2:4], 0x0100) // RD
+	binary.BigEndian.PutUint16(header[4:6], 1)      // QDCOUNT
+	msg = append(msg, header[:]...)
+	for _, label := range splitName(name) {
+		if label == "" {
+			continue // 末尾根点产生的空标签, 由下面的结束字节表示
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	var qtype, qclass [2]byte
+	binary.BigEndian.PutUint16(qtype[:], 1) // A
+	binary.BigEndian.PutUint16(qclass[:], 1)
+	msg = append(msg, qtype[:]...)
+	msg = append(msg, qclass[:]...)
+	return msg
+}
+
+func splitName(name string) []string {
+	var labels []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			labels = append(labels, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(labels, name[start:])
+}
+
+// withFlags 返回 raw 的一份副本, 把 flags 字段(offset 2:4)替换为给定值
+func withFlags(raw []byte, flags uint16) []byte {
+	out := append([]byte(nil), raw...)
+	binary.BigEndian.PutUint16(out[2:4], flags)
+	return out
+}
+
+// withID 返回 raw 的一份副本, 把事务 ID 替换为给定值
+func withID(raw []byte, id uint16) []byte {
+	out := append([]byte(nil), raw...)
+	binary.BigEndian.PutUint16(out[0:2], id)
+	return out
+}
+
+// startEchoServer 启动一个 UDP 服务器, 收到查询后等待 delay, 再用 mutate 构造应答并回复
+func startEchoServer(t *testing.T, delay time.Duration, mutate func(query []byte) []byte) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			query := append([]byte(nil), buf[:n]...)
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			resp := mutate(query)
+			conn.WriteToUDP(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// echoAsAnswer 把查询原样回显并加上一条 TTL=60、地址 10.0.0.1 的 A 记录答案, 作为"正常应答"
+func echoAsAnswer(query []byte) []byte {
+	resp := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(resp[2:4], 0x8180) // QR|RD|RA
+	binary.BigEndian.PutUint16(resp[6:8], 1)      // ANCOUNT=1
+	resp = append(resp, 0xC0, 0x0C)               // name = pointer to question
+	var rrHead [8]byte
+	binary.BigEndian.PutUint16(rrHead[0:2], 1) // TYPE A
+	binary.BigEndian.PutUint16(rrHead[2:4], 1) // CLASS IN
+	binary.BigEndian.PutUint32(rrHead[4:8], 60)
+	resp = append(resp, rrHead[:]...)
+	resp = append(resp, 0, 4) // RDLENGTH
+	resp = append(resp, 10, 0, 0, 1)
+	return resp
+}
+
+func TestForward_SingleUpstreamSuccess(t *testing.T) {
+	addr := startEchoServer(t, 0, echoAsAnswer)
+	f := New([]Upstream{{Addr: addr}}, time.Second)
+
+	query := buildTestQuery(0x1234, "example.com.")
+	resp, err := f.Forward(t.Context(), query)
+	if err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+	if id := binary.BigEndian.Uint16(resp[0:2]); id != 0x1234 {
+		t.Errorf("response transaction id = %#x, want 0x1234", id)
+	}
+}
+
+func TestForward_StaggeredRaceFasterUpstreamWins(t *testing.T) {
+	slow := startEchoServer(t, 200*time.Millisecond, echoAsAnswer)
+	fast := startEchoServer(t, 0, echoAsAnswer)
+
+	f := New([]Upstream{
+		{Addr: slow, StartDelay: 0},
+		{Addr: fast, StartDelay: 10 * time.Millisecond},
+	}, time.Second)
+
+	query := buildTestQuery(0x5678, "example.com.")
+	start := time.Now()
+	resp, err := f.Forward(t.Context(), query)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+	if elapsed >= 150*time.Millisecond {
+		t.Errorf("Forward() took %v, want well under the slow upstream's 200ms delay", elapsed)
+	}
+	if id := binary.BigEndian.Uint16(resp[0:2]); id != 0x5678 {
+		t.Errorf("response transaction id = %#x, want 0x5678", id)
+	}
+}
+
+func TestForward_RejectsTruncatedResponse(t *testing.T) {
+	addr := startEchoServer(t, 0, func(query []byte) []byte {
+		resp := echoAsAnswer(query)
+		return withFlags(resp, 0x8380) // QR|RD|RA|TC
+	})
+	f := New([]Upstream{{Addr: addr}}, 200*time.Millisecond)
+
+	query := buildTestQuery(0x1, "example.com.")
+	if _, err := f.Forward(t.Context(), query); err == nil {
+		t.Fatal("Forward() error = nil, want rejection of truncated response")
+	}
+}
+
+func TestForward_RejectsTransactionIDMismatch(t *testing.T) {
+	addr := startEchoServer(t, 0, func(query []byte) []byte {
+		resp := echoAsAnswer(query)
+		return withID(resp, 0x9999)
+	})
+	f := New([]Upstream{{Addr: addr}}, 200*time.Millisecond)
+
+	query := buildTestQuery(0x1, "example.com.")
+	if _, err := f.Forward(t.Context(), query); err == nil {
+		t.Fatal("Forward() error = nil, want rejection of mismatched transaction id")
+	}
+}
+
+func TestForward_NoUpstreams(t *testing.T) {
+	f := New(nil, time.Second)
+	if _, err := f.Forward(t.Context(), buildTestQuery(1, "example.com.")); err != ErrNoUpstreams {
+		t.Errorf("Forward() error = %v, want ErrNoUpstreams", err)
+	}
+}
+
+func TestForward_AllUpstreamsFail(t *testing.T) {
+	// 端口上没有监听者, 查询会被内核立即拒绝 (ICMP port unreachable) 或超时
+	f := New([]Upstream{{Addr: "127.0.0.1:1"}}, 200*time.Millisecond)
+	if _, err := f.Forward(t.Context(), buildTestQuery(1, "example.com.")); err == nil {
+		t.Fatal("Forward() error = nil, want failure with no upstream reachable")
+	}
+}
@@ -0,0 +1,93 @@
+// Package listener 提供加密 DNS 前端: DNS-over-HTTPS (RFC 8484) 的 DoHHandler,
+// 与 pkg/dns/tcp 的 DNS-over-TLS (RFC 7858) 一样共享同一套 filter.Engine.Check
+// 决策和 pkg/dns 响应构建逻辑 (经 tcp.Process 暴露), 唯一的区别只是"怎么把查询
+// 字节收上来、把响应字节发回去"。DNS-over-QUIC (RFC 9250) 只有一个总是返回
+// ErrDoQUnavailable 的占位入口 ListenAndServeDoQ, 不处理任何查询。
+package listener
+
+import (
+	"encoding/base64"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"xdp-dns/pkg/dns/tcp"
+	"xdp-dns/pkg/filter"
+)
+
+// dohMaxMessageSize 是单条 DoH 查询/响应报文的上限 (RFC 8484 §6 建议不超过
+// 65535 字节的 TCP 上限一致)
+const dohMaxMessageSize = 65535
+
+// dnsMessageContentType 是 RFC 8484 §6 规定的 wire-format content type
+const dnsMessageContentType = "application/dns-message"
+
+// DoHHandler 是 DNS-over-HTTPS (RFC 8484) 的 http.Handler 实现, 同时支持
+// GET (查询报文以 base64url 无填充编码放在 ?dns= 参数) 和 POST
+// (application/dns-message 请求体) 两种 wire-format 用法
+type DoHHandler struct {
+	Engine *filter.Engine
+}
+
+// NewDoHHandler 创建新的 DoH 处理器
+func NewDoHHandler(engine *filter.Engine) *DoHHandler {
+	return &DoHHandler{Engine: engine}
+}
+
+// ServeHTTP 实现 http.Handler
+func (h *DoHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var query []byte
+
+	switch r.Method {
+	case http.MethodGet:
+		encoded := r.URL.Query().Get("dns")
+		if encoded == "" {
+			http.Error(w, "missing dns query parameter", http.StatusBadRequest)
+			return
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			http.Error(w, "invalid base64url dns query parameter", http.StatusBadRequest)
+			return
+		}
+		query = decoded
+
+	case http.MethodPost:
+		if ct := r.Header.Get("Content-Type"); ct != dnsMessageContentType {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, dohMaxMessageSize+1))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		query = body
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if len(query) == 0 || len(query) > dohMaxMessageSize {
+		http.Error(w, "invalid dns query length", http.StatusBadRequest)
+		return
+	}
+
+	srcIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	response := tcp.Process(h.Engine, query, srcIP)
+	if response == nil {
+		http.Error(w, "failed to process dns query", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", dnsMessageContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+	if _, err := w.Write(response); err != nil {
+		log.Printf("listener/doh: write response error: %v", err)
+	}
+}
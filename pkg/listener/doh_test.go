@@ -0,0 +1,135 @@
+package listener
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/filter"
+)
+
+// buildTestQuery 构建一个简单的 A 查询包, 复用 pkg/dns/tcp 的测试约定
+func buildTestQuery(domain string) []byte {
+	packet := []byte{
+		0x12, 0x34, // ID
+		0x01, 0x00, // Flags
+		0x00, 0x01, // QDCount = 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	start := 0
+	for i := 0; i <= len(domain); i++ {
+		if i == len(domain) || domain[i] == '.' {
+			packet = append(packet, byte(i-start))
+			packet = append(packet, []byte(domain[start:i])...)
+			start = i + 1
+		}
+	}
+	packet = append(packet, 0, 0x00, 0x01, 0x00, 0x01)
+	return packet
+}
+
+func newTestEngine(t *testing.T) *filter.Engine {
+	t.Helper()
+	engine, err := filter.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	engine.AddRule(filter.Rule{
+		ID:      "block-ads",
+		Enabled: true,
+		Action:  filter.ActionBlock,
+		Domains: []string{"ads.example.com"},
+	})
+	return engine
+}
+
+func TestDoHHandler_GET_BlockedDomain(t *testing.T) {
+	handler := NewDoHHandler(newTestEngine(t))
+
+	query := buildTestQuery("ads.example.com")
+	encoded := base64.RawURLEncoding.EncodeToString(query)
+
+	req := httptest.NewRequest(http.MethodGet, "/dns-query?dns="+encoded, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body = %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != dnsMessageContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, dnsMessageContentType)
+	}
+
+	parser := dns.NewParser()
+	msg, err := parser.Parse(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+	if msg.GetRCode() != dns.RCodeNXDomain {
+		t.Errorf("RCODE = %d, want NXDOMAIN", msg.GetRCode())
+	}
+}
+
+func TestDoHHandler_POST_BlockedDomain(t *testing.T) {
+	handler := NewDoHHandler(newTestEngine(t))
+
+	query := buildTestQuery("ads.example.com")
+	req := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(query))
+	req.Header.Set("Content-Type", dnsMessageContentType)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body = %s", rec.Code, rec.Body.String())
+	}
+
+	parser := dns.NewParser()
+	msg, err := parser.Parse(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Parse(response) error = %v", err)
+	}
+	if msg.GetRCode() != dns.RCodeNXDomain {
+		t.Errorf("RCODE = %d, want NXDOMAIN", msg.GetRCode())
+	}
+}
+
+func TestDoHHandler_GET_MissingQueryParam(t *testing.T) {
+	handler := NewDoHHandler(newTestEngine(t))
+
+	req := httptest.NewRequest(http.MethodGet, "/dns-query", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestDoHHandler_POST_WrongContentType(t *testing.T) {
+	handler := NewDoHHandler(newTestEngine(t))
+
+	req := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader([]byte("x")))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status = %d, want 415", rec.Code)
+	}
+}
+
+func TestDoHHandler_MethodNotAllowed(t *testing.T) {
+	handler := NewDoHHandler(newTestEngine(t))
+
+	req := httptest.NewRequest(http.MethodDelete, "/dns-query", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,15 @@
+package listener
+
+import "errors"
+
+// ErrDoQUnavailable DNS-over-QUIC (RFC 9250) 暂不可用: go.mod 中没有引入任何
+// QUIC 实现 (如 quic-go), 而本仓库的构建环境无法联网拉取新依赖, 因此这里只给出
+// 一个诚实的占位, 不伪造一个假的监听器。等依赖可用时, 应复用 tcp.Process 做
+// 查询解码/响应构建, 与 DoHHandler/Server.ListenAndServeTLS 共享同一套决策逻辑。
+var ErrDoQUnavailable = errors.New("listener: DNS-over-QUIC requires a QUIC library not present in go.mod")
+
+// ListenAndServeDoQ 是 DNS-over-QUIC (RFC 9250) 监听入口的占位实现, 目前总是
+// 返回 ErrDoQUnavailable
+func ListenAndServeDoQ(addr string) error {
+	return ErrDoQUnavailable
+}
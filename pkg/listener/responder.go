@@ -0,0 +1,10 @@
+package listener
+
+// Responder 抽象"把构建好的 DNS 响应字节发送回客户端"这一步。目前仓库里没有类型
+// 实现它: UDP 快路径 (internal/worker.Pool.sendResponse) 需要原始数据包和包信息,
+// DNS-over-TCP/TLS (pkg/dns/tcp.Server) 和本包的 DoHHandler 各自直接把 tcp.Process
+// 返回的响应字节写回连接。
+type Responder interface {
+	// Respond 将 dnsResponse (一条完整的 DNS 报文) 发送给 srcIP 对应的客户端
+	Respond(srcIP string, dnsResponse []byte) error
+}
@@ -61,6 +61,102 @@ var (
 		Name: "xdp_dns_rules_total",
 		Help: "Total number of filter rules",
 	})
+
+	// DNS-over-TCP 回落数据通道指标
+	tcpConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xdp_dns_tcp_connections",
+		Help: "Current number of open DNS-over-TCP connections",
+	})
+
+	tcpQueriesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xdp_dns_tcp_queries_total",
+		Help: "Total DNS queries served over the TCP fallback listener",
+	})
+
+	// RPZ 命中统计, 按区域分类
+	rpzHitsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xdp_dns_rpz_hits_total",
+		Help: "Total queries matched by a Response Policy Zone rule, by zone",
+	}, []string{"zone"})
+
+	// RPZ 命中统计, 按区域和触发策略 (nxdomain/nodata/passthru/drop/rewrite/cname-rewrite/tcp-only) 分类
+	rpzPolicyHitsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xdp_dns_rpz_policy_hits_total",
+		Help: "Total queries matched by a Response Policy Zone rule, by zone and policy trigger type",
+	}, []string{"zone", "policy"})
+
+	// 速率限制丢弃计数, 按来源分类
+	ratelimitDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xdp_dns_ratelimit_dropped_total",
+		Help: "Total queries dropped due to exceeding the per-source token bucket, by source",
+	}, []string{"source"})
+
+	// 热重载指标
+	ruleReloadDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xdp_dns_rule_reload_duration_seconds",
+		Help: "Duration of the most recent hot rule reload",
+	})
+
+	ruleReloadDelta = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xdp_dns_rule_reload_delta",
+		Help: "Change in total rule count introduced by the most recent hot reload (can be negative)",
+	})
+
+	// Fake DNS 子系统指标
+	fakednsAllocationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xdp_dns_fakedns_allocations_total",
+		Help: "Total domain-to-IP allocations made by the fake DNS subsystem",
+	})
+
+	fakednsCollisionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xdp_dns_fakedns_collisions_total",
+		Help: "Total address probes that landed on an already-occupied fake DNS pool slot",
+	})
+
+	fakednsPoolUtilization = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xdp_dns_fakedns_pool_utilization",
+		Help: "Fraction of the fake DNS domain<->ip mapping table currently in use (0.0-1.0)",
+	})
+
+	// 可插拔规则来源 (hosts/AdGuard/dnsmasq/domain-list) 的重载统计, 按来源分类
+	ruleSourceReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xdp_dns_rule_source_reloads_total",
+		Help: "Total reload attempts of a pluggable rule source, by source and result (ok/error)",
+	}, []string{"source", "result"})
+
+	ruleSourceRulesTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xdp_dns_rule_source_rules_total",
+		Help: "Number of rules currently contributed by a pluggable rule source",
+	}, []string{"source"})
+
+	// ActionForward 上游转发统计, 按上游地址和结果 (ok/error) 分类
+	forwarderAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xdp_dns_forwarder_attempts_total",
+		Help: "Total upstream forwarding attempts, by upstream address and result (ok/error)",
+	}, []string{"upstream", "result"})
+
+	forwarderLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "xdp_dns_forwarder_latency_seconds",
+		Help:    "Upstream forwarding round-trip latency in seconds, by upstream address",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 10),
+	}, []string{"upstream"})
+
+	// pkg/dnscache 响应缓存统计
+	dnsCacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xdp_dns_cache_hits_total",
+		Help: "Total DNS response cache lookups that found a usable entry",
+	})
+
+	dnsCacheMissesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xdp_dns_cache_misses_total",
+		Help: "Total DNS response cache lookups that found no usable entry",
+	})
+
+	// 缓存条目淘汰计数, 按原因 (expired/capacity/invalidated) 分类
+	dnsCacheEvictionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xdp_dns_cache_evictions_total",
+		Help: "Total DNS response cache entries removed, by reason",
+	}, []string{"reason"})
 )
 
 func init() {
@@ -75,6 +171,23 @@ func init() {
 		packetLatency,
 		xdpKernelDrops,
 		rulesTotal,
+		tcpConnections,
+		tcpQueriesTotal,
+		rpzHitsTotal,
+		rpzPolicyHitsTotal,
+		ratelimitDroppedTotal,
+		ruleReloadDuration,
+		ruleReloadDelta,
+		fakednsAllocationsTotal,
+		fakednsCollisionsTotal,
+		fakednsPoolUtilization,
+		ruleSourceReloadsTotal,
+		ruleSourceRulesTotal,
+		forwarderAttemptsTotal,
+		forwarderLatency,
+		dnsCacheHitsTotal,
+		dnsCacheMissesTotal,
+		dnsCacheEvictionsTotal,
 	)
 }
 
@@ -169,3 +282,90 @@ func ObserveLatency(duration time.Duration) {
 	packetLatency.Observe(duration.Seconds())
 }
 
+// IncTCPConnections 增加当前 DNS-over-TCP 连接数
+func IncTCPConnections() {
+	tcpConnections.Inc()
+}
+
+// DecTCPConnections 减少当前 DNS-over-TCP 连接数
+func DecTCPConnections() {
+	tcpConnections.Dec()
+}
+
+// IncTCPQueries 增加 DNS-over-TCP 查询计数
+func IncTCPQueries() {
+	tcpQueriesTotal.Inc()
+}
+
+// IncRPZHit 增加指定区域的 RPZ 命中计数
+func IncRPZHit(zone string) {
+	rpzHitsTotal.WithLabelValues(zone).Inc()
+}
+
+// IncRPZPolicyHit 增加指定区域、指定触发策略的 RPZ 命中计数
+func IncRPZPolicyHit(zone, policy string) {
+	rpzPolicyHitsTotal.WithLabelValues(zone, policy).Inc()
+}
+
+// IncRateLimitDropped 增加指定来源的速率限制丢弃计数
+func IncRateLimitDropped(source string) {
+	ratelimitDroppedTotal.WithLabelValues(source).Inc()
+}
+
+// ObserveRuleReload 记录最近一次热重载的耗时和规则总数变化量
+func ObserveRuleReload(duration time.Duration, ruleCountDelta int) {
+	ruleReloadDuration.Set(duration.Seconds())
+	ruleReloadDelta.Set(float64(ruleCountDelta))
+}
+
+// IncFakeDNSAllocation 增加 Fake DNS 分配计数
+func IncFakeDNSAllocation() {
+	fakednsAllocationsTotal.Inc()
+}
+
+// IncFakeDNSCollision 增加 Fake DNS 地址探测碰撞计数
+func IncFakeDNSCollision() {
+	fakednsCollisionsTotal.Inc()
+}
+
+// SetFakeDNSPoolUtilization 设置 Fake DNS 映射表占用率 (0.0~1.0)
+func SetFakeDNSPoolUtilization(ratio float64) {
+	fakednsPoolUtilization.Set(ratio)
+}
+
+// ObserveRuleSourceReload 记录一次可插拔规则来源 (hosts/AdGuard/dnsmasq/domain-list)
+// 重载的结果和当前规则数; err 非 nil 时只记录失败计数, 不更新规则数 (保留上一次成功值)
+func ObserveRuleSourceReload(source string, ruleCount int, err error) {
+	if err != nil {
+		ruleSourceReloadsTotal.WithLabelValues(source, "error").Inc()
+		return
+	}
+	ruleSourceReloadsTotal.WithLabelValues(source, "ok").Inc()
+	ruleSourceRulesTotal.WithLabelValues(source).Set(float64(ruleCount))
+}
+
+// ObserveForwarderAttempt 记录一次 ActionForward 上游转发尝试的结果和耗时
+func ObserveForwarderAttempt(upstream string, duration time.Duration, err error) {
+	forwarderLatency.WithLabelValues(upstream).Observe(duration.Seconds())
+	if err != nil {
+		forwarderAttemptsTotal.WithLabelValues(upstream, "error").Inc()
+		return
+	}
+	forwarderAttemptsTotal.WithLabelValues(upstream, "ok").Inc()
+}
+
+// IncDNSCacheHit 增加 pkg/dnscache 响应缓存命中计数
+func IncDNSCacheHit() {
+	dnsCacheHitsTotal.Inc()
+}
+
+// IncDNSCacheMiss 增加 pkg/dnscache 响应缓存未命中计数
+func IncDNSCacheMiss() {
+	dnsCacheMissesTotal.Inc()
+}
+
+// IncDNSCacheEviction 增加 pkg/dnscache 响应缓存条目淘汰计数, reason 取值
+// "expired" (TTL 到期)、"capacity" (字节容量超限)、"invalidated" (规则变更/管理端 flush)
+func IncDNSCacheEviction(reason string) {
+	dnsCacheEvictionsTotal.WithLabelValues(reason).Inc()
+}
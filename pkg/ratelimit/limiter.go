@@ -0,0 +1,239 @@
+// Package ratelimit 实现按来源 IP/CIDR 的令牌桶限速, 供 filter.Engine 在用户态慢路径
+// 调用。限速只在用户态进行: 本包不维护可被 XDP 程序共享的 eBPF map, 洪泛流量的每个
+// 包仍会先到达用户态再被丢弃。
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"xdp-dns/pkg/metrics"
+)
+
+// maxDynamicBuckets 是 CIDR 规则动态创建的来源级别令牌桶上限; 这些桶的 key 直接来自
+// 请求方 IP (lookupLocked), 没有上限的话海量伪造来源就能让 buckets 无限膨胀内存,
+// 而这张表本身还整份暴露在 pkg/ratelimit/server.go 的 /ratelimit/buckets 接口上。
+// 超过上限后按最久未使用淘汰。var 而非 const 是为了方便测试覆盖为一个小值。
+var maxDynamicBuckets = 65536
+
+// Config 一条限速规则配置, 与 filter.RateLimitConfig 字段一一对应
+// (ratelimit 包不依赖 filter, 避免两者互相导入, 由调用方在装配引擎时做字段转换)
+type Config struct {
+	Source           string // 来源 IP 或 CIDR
+	QueriesPerSecond int    // 每秒查询数
+	Burst            int    // 突发容量, 0 表示等于 QueriesPerSecond
+}
+
+// bucket 一个来源的令牌桶状态
+type bucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64 // 每秒补充的令牌数 (queries_per_second)
+}
+
+// refill 按经过的时间补充令牌, 上限为 capacity
+func (b *bucket) refill(elapsed time.Duration) {
+	b.tokens += b.rate * elapsed.Seconds()
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// take 尝试消费一个令牌, 成功返回 true
+func (b *bucket) take() bool {
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// cidrRule 一条按 CIDR 匹配的限速规则, rules 按前缀长度从长到短排序实现最长前缀匹配
+type cidrRule struct {
+	network *net.IPNet
+	rate    float64
+	burst   float64
+}
+
+// Limiter 维护按来源 IP/CIDR 划分的令牌桶, 由定时器周期性补充令牌
+type Limiter struct {
+	mu         sync.Mutex
+	exact      map[string]*bucket       // 精确匹配 (/32 或 /128), 键为 net.IP.String()
+	cidrs      []cidrRule               // 按前缀长度从长到短排序, 用于 LPM 匹配
+	buckets    map[string]*bucket       // CIDR 规则命中后, 按实际来源 IP 分配的独立令牌桶
+	bucketLRU  *list.List               // buckets 的访问顺序, 最近使用的在前, 淘汰从尾部开始
+	bucketElem map[string]*list.Element // key -> bucketLRU 中对应的元素, 免去线性查找
+	last       time.Time
+}
+
+// NewLimiter 根据配置列表构建 Limiter
+// Source 既可以是单个 IP (精确匹配), 也可以是 CIDR (最长前缀匹配)
+func NewLimiter(configs []Config) (*Limiter, error) {
+	l := &Limiter{
+		exact:      make(map[string]*bucket),
+		buckets:    make(map[string]*bucket),
+		bucketLRU:  list.New(),
+		bucketElem: make(map[string]*list.Element),
+		last:       timeNow(),
+	}
+
+	for _, rc := range configs {
+		if rc.QueriesPerSecond <= 0 {
+			return nil, fmt.Errorf("ratelimit: source %s: queries_per_second must be positive", rc.Source)
+		}
+		burst := rc.Burst
+		if burst <= 0 {
+			burst = rc.QueriesPerSecond
+		}
+
+		if ip := net.ParseIP(rc.Source); ip != nil {
+			l.exact[ip.String()] = &bucket{
+				tokens:   float64(burst),
+				capacity: float64(burst),
+				rate:     float64(rc.QueriesPerSecond),
+			}
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(rc.Source)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimit: source %q is neither a valid IP nor CIDR: %w", rc.Source, err)
+		}
+		l.cidrs = append(l.cidrs, cidrRule{network: network, rate: float64(rc.QueriesPerSecond), burst: float64(burst)})
+	}
+
+	// 前缀越长越精确, 排在前面优先匹配
+	for i := 1; i < len(l.cidrs); i++ {
+		for j := i; j > 0 && prefixLen(l.cidrs[j].network) > prefixLen(l.cidrs[j-1].network); j-- {
+			l.cidrs[j], l.cidrs[j-1] = l.cidrs[j-1], l.cidrs[j]
+		}
+	}
+
+	return l, nil
+}
+
+func prefixLen(n *net.IPNet) int {
+	ones, _ := n.Mask.Size()
+	return ones
+}
+
+// Allow 判断来源 IP 是否还有可用令牌; 没有任何规则匹配时视为不限速, 直接放行
+func (l *Limiter) Allow(srcIP string) bool {
+	ip := net.ParseIP(srcIP)
+	if ip == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.lookupLocked(ip)
+	if b == nil {
+		return true
+	}
+
+	if !b.take() {
+		metrics.IncRateLimitDropped(srcIP)
+		return false
+	}
+	return true
+}
+
+// lookupLocked 返回 srcIP 对应的令牌桶, 必要时按命中的 CIDR 规则为其单独创建一个
+func (l *Limiter) lookupLocked(ip net.IP) *bucket {
+	if b, ok := l.exact[ip.String()]; ok {
+		return b
+	}
+
+	for _, rule := range l.cidrs {
+		if rule.network.Contains(ip) {
+			key := ip.String()
+			if b, ok := l.buckets[key]; ok {
+				l.bucketLRU.MoveToFront(l.bucketElem[key])
+				return b
+			}
+			b := &bucket{tokens: rule.burst, capacity: rule.burst, rate: rule.rate}
+			l.buckets[key] = b
+			l.bucketElem[key] = l.bucketLRU.PushFront(key)
+			l.evictOldestLocked()
+			return b
+		}
+	}
+	return nil
+}
+
+// evictOldestLocked 把 buckets 淘汰到 maxDynamicBuckets 以内, 调用方需持有 l.mu
+func (l *Limiter) evictOldestLocked() {
+	for len(l.buckets) > maxDynamicBuckets {
+		oldest := l.bucketLRU.Back()
+		if oldest == nil {
+			return
+		}
+		key := oldest.Value.(string)
+		l.bucketLRU.Remove(oldest)
+		delete(l.bucketElem, key)
+		delete(l.buckets, key)
+	}
+}
+
+// Run 周期性补充所有令牌桶, 直到 ctx 结束
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.refillAll()
+		}
+	}
+}
+
+func (l *Limiter) refillAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := timeNow()
+	elapsed := now.Sub(l.last)
+	l.last = now
+
+	for _, b := range l.exact {
+		b.refill(elapsed)
+	}
+	for _, b := range l.buckets {
+		b.refill(elapsed)
+	}
+}
+
+// BucketState 是某个来源当前令牌桶状态的只读快照, 供 REST 接口展示
+type BucketState struct {
+	Source   string  `json:"source"`
+	Tokens   float64 `json:"tokens"`
+	Capacity float64 `json:"capacity"`
+}
+
+// Snapshot 返回当前所有令牌桶的状态, 用于 inspect 接口
+func (l *Limiter) Snapshot() []BucketState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	states := make([]BucketState, 0, len(l.exact)+len(l.buckets))
+	for src, b := range l.exact {
+		states = append(states, BucketState{Source: src, Tokens: b.tokens, Capacity: b.capacity})
+	}
+	for src, b := range l.buckets {
+		states = append(states, BucketState{Source: src, Tokens: b.tokens, Capacity: b.capacity})
+	}
+	return states
+}
+
+// timeNow 包装 time.Now, 便于后续如有需要可在测试中替换
+func timeNow() time.Time {
+	return time.Now()
+}
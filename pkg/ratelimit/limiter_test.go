@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLimiter_ExactSourceEnforcesBurst(t *testing.T) {
+	l, err := NewLimiter([]Config{
+		{Source: "192.168.1.1", QueriesPerSecond: 10, Burst: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+
+	if !l.Allow("192.168.1.1") {
+		t.Fatal("1st query should be allowed (burst=2)")
+	}
+	if !l.Allow("192.168.1.1") {
+		t.Fatal("2nd query should be allowed (burst=2)")
+	}
+	if l.Allow("192.168.1.1") {
+		t.Fatal("3rd query should be rejected, bucket should be empty")
+	}
+}
+
+func TestLimiter_CIDRMatchUsesLongestPrefix(t *testing.T) {
+	l, err := NewLimiter([]Config{
+		{Source: "10.0.0.0/8", QueriesPerSecond: 100, Burst: 100},
+		{Source: "10.0.0.0/24", QueriesPerSecond: 1, Burst: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+
+	if !l.Allow("10.0.0.5") {
+		t.Fatal("1st query in the more specific /24 should be allowed (burst=1)")
+	}
+	if l.Allow("10.0.0.5") {
+		t.Fatal("2nd query should be rejected by the more specific /24 rule, not fall back to /8")
+	}
+
+	// A different host under the same /8 but outside the /24 keeps its own bucket.
+	if !l.Allow("10.1.0.5") {
+		t.Fatal("host outside the /24 should be governed by the /8 rule and allowed")
+	}
+}
+
+func TestLimiter_UnconfiguredSourceIsNotLimited(t *testing.T) {
+	l, err := NewLimiter(nil)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if !l.Allow("8.8.8.8") {
+			t.Fatalf("query %d should be allowed, no rate limit configured", i)
+		}
+	}
+}
+
+func TestLimiter_RefillRestoresTokensOverTime(t *testing.T) {
+	l, err := NewLimiter([]Config{
+		{Source: "192.168.1.1", QueriesPerSecond: 10, Burst: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+
+	if !l.Allow("192.168.1.1") {
+		t.Fatal("1st query should be allowed")
+	}
+	if l.Allow("192.168.1.1") {
+		t.Fatal("2nd query should be rejected before refill")
+	}
+
+	l.mu.Lock()
+	l.exact["192.168.1.1"].refill(time.Second)
+	l.mu.Unlock()
+
+	if !l.Allow("192.168.1.1") {
+		t.Fatal("query after refill should be allowed")
+	}
+}
+
+func TestLimiter_RunReplenishesTokensOverTime(t *testing.T) {
+	l, err := NewLimiter([]Config{
+		{Source: "192.168.1.1", QueriesPerSecond: 1000, Burst: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Run(ctx, 10*time.Millisecond)
+
+	if !l.Allow("192.168.1.1") {
+		t.Fatal("1st query should be allowed (burst=1)")
+	}
+	if l.Allow("192.168.1.1") {
+		t.Fatal("2nd query should be rejected before Run has refilled")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if l.Allow("192.168.1.1") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Run() never replenished tokens within 1s")
+}
+
+func TestLimiter_DynamicBucketsAreBounded(t *testing.T) {
+	orig := maxDynamicBuckets
+	maxDynamicBuckets = 4
+	defer func() { maxDynamicBuckets = orig }()
+
+	l, err := NewLimiter([]Config{
+		{Source: "10.0.0.0/8", QueriesPerSecond: 10, Burst: 10},
+	})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Allow(fmt.Sprintf("10.0.0.%d", i))
+	}
+
+	l.mu.Lock()
+	got := len(l.buckets)
+	l.mu.Unlock()
+
+	if got != maxDynamicBuckets {
+		t.Fatalf("len(buckets) = %d, want bounded to %d", got, maxDynamicBuckets)
+	}
+
+	// The most recently used source must survive eviction.
+	if !l.Allow("10.0.0.9") {
+		t.Fatalf("most recently used bucket should still have its full burst")
+	}
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Server 暴露一个只读 REST 接口, 用于排查各来源当前的令牌桶状态
+type Server struct {
+	limiter *Limiter
+	addr    string
+	server  *http.Server
+}
+
+// NewServer 创建一个绑定到 addr 的状态查询服务器
+func NewServer(limiter *Limiter, addr string) *Server {
+	return &Server{limiter: limiter, addr: addr}
+}
+
+// Start 启动 HTTP 服务器, 阻塞直到 Stop 被调用或发生错误
+func (s *Server) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ratelimit/buckets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.limiter.Snapshot()); err != nil {
+			log.Printf("ratelimit: encode snapshot: %v", err)
+		}
+	})
+
+	s.server = &http.Server{Addr: s.addr, Handler: mux}
+
+	log.Printf("Starting ratelimit inspection server on %s", s.addr)
+	return s.server.ListenAndServe()
+}
+
+// Stop 优雅关闭服务器
+func (s *Server) Stop(ctx context.Context) error {
+	if s.server != nil {
+		return s.server.Shutdown(ctx)
+	}
+	return nil
+}
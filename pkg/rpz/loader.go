@@ -0,0 +1,174 @@
+package rpz
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"xdp-dns/pkg/filter"
+)
+
+// Loader 周期性拉取一个 RPZ 区域并把解析结果安装进 filter.Engine
+//
+// 支持两种分发方式: 运营上更常见的 HTTPS + ETag 条件请求 (Spamhaus、Farsight 等多数
+// 公开 feed 的实际分发形式, 见 NewLoader), 以及标准的 AXFR-over-TCP 区域传送、以 SOA
+// 序列号判断区域是否变化后再决定是否传送 (见 NewAXFRLoader 和 xfr.go)。两者共享同一套
+// 规则安装/替换逻辑。
+type Loader struct {
+	engine *filter.Engine
+	zone   string
+	url    string
+	client *http.Client
+	etag   string
+
+	transport  *XFRTransport
+	haveSerial bool
+	lastSerial uint32
+
+	// RuleIDPrefix 是本区域所有规则的 ID 前缀, 用于在 Engine 中区分归属与重新加载时清理旧规则
+	RuleIDPrefix string
+}
+
+// NewLoader 创建一个基于 HTTPS + ETag 的 RPZ Loader, 并把命中计数 Sink 注册到 engine 上
+func NewLoader(engine *filter.Engine, zone, url string) *Loader {
+	l := &Loader{
+		engine:       engine,
+		zone:         zone,
+		url:          url,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		RuleIDPrefix: "rpz:" + zone + "#",
+	}
+	engine.AddSink(NewHitCounter(zone, l.RuleIDPrefix))
+	return l
+}
+
+// NewAXFRLoader 创建一个基于 AXFR-over-TCP 区域传送的 RPZ Loader, 并把命中计数 Sink
+// 注册到 engine 上。每次 Refresh 先查询 SOA 序列号, 序列号未变化时跳过传送
+func NewAXFRLoader(engine *filter.Engine, zone, addr string) *Loader {
+	l := &Loader{
+		engine:       engine,
+		zone:         zone,
+		transport:    NewXFRTransport(addr, zone),
+		RuleIDPrefix: "rpz:" + zone + "#",
+	}
+	engine.AddSink(NewHitCounter(zone, l.RuleIDPrefix))
+	return l
+}
+
+// Refresh 拉取一次区域数据, 无需更新时返回 (false, nil)
+func (l *Loader) Refresh(ctx context.Context) (bool, error) {
+	if l.transport != nil {
+		return l.refreshXFR(ctx)
+	}
+	return l.refreshHTTP(ctx)
+}
+
+// refreshHTTP 通过 HTTPS + ETag 条件请求拉取一次区域数据, 命中 ETag 时返回 (false, nil)
+func (l *Loader) refreshHTTP(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return false, fmt.Errorf("rpz: build request for zone %s: %w", l.zone, err)
+	}
+	if l.etag != "" {
+		req.Header.Set("If-None-Match", l.etag)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("rpz: fetch zone %s: %w", l.zone, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("rpz: unexpected status %d fetching zone %s", resp.StatusCode, l.zone)
+	}
+
+	result, err := ParseZone(resp.Body, l.zone)
+	if err != nil {
+		return false, err
+	}
+
+	l.install(result)
+	l.etag = resp.Header.Get("ETag")
+	return true, nil
+}
+
+// refreshXFR 查询 SOA 序列号, 序列号未变化时跳过传送, 否则执行一次完整的 AXFR 传送
+func (l *Loader) refreshXFR(ctx context.Context) (bool, error) {
+	serial, err := l.transport.SOASerial(ctx)
+	if err != nil {
+		return false, err
+	}
+	if l.haveSerial && serial == l.lastSerial {
+		return false, nil
+	}
+
+	result, err := l.transport.TransferAXFR(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	l.install(result)
+	l.lastSerial = serial
+	l.haveSerial = true
+	return true, nil
+}
+
+// install 把解析出的条目替换为新的 Engine 规则, 规则 ID 带有区域前缀便于下次整体移除,
+// 并附带策略类型后缀供 HitCounter 拆分出按策略分类的命中计数
+func (l *Loader) install(result *ParseResult) {
+	// 先移除本区域上一次安装的全部规则, 区域中已删除或改变策略的条目不会残留
+	for _, old := range l.engine.GetRules() {
+		if strings.HasPrefix(old.ID, l.RuleIDPrefix) {
+			l.engine.RemoveRule(old.ID)
+		}
+	}
+
+	for i, entry := range result.Entries {
+		rule := filter.Rule{
+			ID:      fmt.Sprintf("%s%d%s%s", l.RuleIDPrefix, i, rulePolicySep, entry.Policy),
+			Enabled: true,
+			Action:  entry.Action,
+			Domains: []string{entry.Name},
+		}
+		if entry.Action == filter.ActionRedirect {
+			rule.RedirectIP = entry.RedirectIP
+			rule.RedirectTTL = entry.TTL
+		}
+
+		l.engine.RemoveRule(rule.ID)
+		l.engine.AddRule(rule)
+	}
+
+	if result.SkippedTriggers > 0 {
+		log.Printf("rpz: zone %s skipped %d entries with unsupported trigger types (IP/NSDNAME/NSIP)",
+			l.zone, result.SkippedTriggers)
+	}
+}
+
+// Run 按 interval 周期性轮询直到 ctx 结束, 单次失败只记录日志不终止循环
+func (l *Loader) Run(ctx context.Context, interval time.Duration) {
+	if _, err := l.Refresh(ctx); err != nil {
+		log.Printf("rpz: initial refresh of zone %s failed: %v", l.zone, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := l.Refresh(ctx); err != nil {
+				log.Printf("rpz: refresh of zone %s failed: %v", l.zone, err)
+			}
+		}
+	}
+}
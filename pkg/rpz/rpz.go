@@ -0,0 +1,201 @@
+// Package rpz 把 BIND 风格的 Response Policy Zone (RPZ) 区域文件转换成 filter.Rule,
+// 让运营者可以直接复用 Spamhaus/Farsight 等既有的 RPZ 威胁情报 feed, 而不必手工把
+// 每条记录翻译成 YAML 规则。
+//
+// 目前只支持 QNAME 触发器 (owner name 本身即是被处置的域名), 这也是绝大多数公开
+// RPZ feed 的主要形式。NSDNAME/NSIP/IP 触发器依赖权威服务器元数据或客户端请求源
+// 的反向匹配, 当前过滤引擎只按查询域名匹配, 无法使其生效, 解析时会被跳过并计入
+// ParseResult.SkippedTriggers, 留给后续接入真实解析链路时处理。
+package rpz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"xdp-dns/pkg/filter"
+	"xdp-dns/pkg/metrics"
+)
+
+// TriggerType RPZ 触发器类型 (RFC draft-vixie-dnsop-dns-rpz)
+type TriggerType int
+
+const (
+	TriggerQNAME   TriggerType = iota // 被阻断的域名本身
+	TriggerIP                         // rpz-ip: 应答 IP 匹配
+	TriggerNSDNAME                    // rpz-nsdname: 权威 NS 域名匹配
+	TriggerNSIP                       // rpz-nsip: 权威 NS 的 IP 匹配
+)
+
+// Entry 一条已解析的 RPZ 规则
+type Entry struct {
+	Trigger    TriggerType
+	Name       string // QNAME 触发器对应的域名 (已去除 rpz 后缀)
+	Action     filter.Action
+	Policy     string // 触发的策略类型, 如 "nxdomain"/"nodata"/"passthru"/"drop"/"rewrite", 用于按策略分类计数
+	RedirectIP net.IP // 仅 local-data 重写 (A/AAAA) 时有效
+	TTL        uint32
+}
+
+// ParseResult 解析结果
+type ParseResult struct {
+	Entries         []Entry
+	SkippedTriggers int // 已识别但当前引擎无法生效的触发器数量 (IP/NSDNAME/NSIP)
+}
+
+// ParseZone 解析 RPZ 区域文件 (AXFR 风格的纯文本 zone 格式)
+func ParseZone(r io.Reader, zoneName string) (*ParseResult, error) {
+	result := &ParseResult{}
+	suffix := "." + strings.TrimSuffix(zoneName, ".")
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "$") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		owner := strings.TrimSuffix(strings.TrimSuffix(fields[0], suffix), ".")
+		trigger, name := classifyTrigger(owner)
+
+		rrType, rdataIdx := findRRType(fields)
+		if rrType == "" {
+			continue
+		}
+
+		switch rrType {
+		case "CNAME":
+			if rdataIdx >= len(fields) {
+				continue
+			}
+			if trigger != TriggerQNAME {
+				result.SkippedTriggers++
+				continue
+			}
+			action, policy, ok := classifyCNAMETarget(fields[rdataIdx])
+			if !ok {
+				continue
+			}
+			result.Entries = append(result.Entries, Entry{Trigger: trigger, Name: name, Action: action, Policy: policy})
+
+		case "A", "AAAA":
+			if trigger != TriggerQNAME {
+				result.SkippedTriggers++
+				continue
+			}
+			if rdataIdx >= len(fields) {
+				continue
+			}
+			ip := net.ParseIP(fields[rdataIdx])
+			if ip == nil {
+				continue
+			}
+			result.Entries = append(result.Entries, Entry{
+				Trigger: trigger, Name: name, Action: filter.ActionRedirect, Policy: "rewrite", RedirectIP: ip, TTL: 300,
+			})
+
+		default:
+			if trigger != TriggerQNAME {
+				result.SkippedTriggers++
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("rpz: scan zone %s: %w", zoneName, err)
+	}
+	return result, nil
+}
+
+// findRRType 跳过可选的 TTL/CLASS 字段, 返回记录类型及其 RDATA 起始下标
+func findRRType(fields []string) (string, int) {
+	for i := 1; i < len(fields); i++ {
+		f := strings.ToUpper(fields[i])
+		if f == "IN" || f == "CH" {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[i]); err == nil {
+			continue // TTL
+		}
+		return f, i + 1
+	}
+	return "", 0
+}
+
+// classifyTrigger 根据 owner name 的 rpz 后缀判断触发器类型
+func classifyTrigger(owner string) (TriggerType, string) {
+	switch {
+	case strings.HasSuffix(owner, ".rpz-ip"):
+		return TriggerIP, strings.TrimSuffix(owner, ".rpz-ip")
+	case strings.HasSuffix(owner, ".rpz-nsdname"):
+		return TriggerNSDNAME, strings.TrimSuffix(owner, ".rpz-nsdname")
+	case strings.HasSuffix(owner, ".rpz-nsip"):
+		return TriggerNSIP, strings.TrimSuffix(owner, ".rpz-nsip")
+	default:
+		return TriggerQNAME, owner
+	}
+}
+
+// classifyCNAMETarget 把 RPZ 规范定义的几种 CNAME 目标映射到本引擎现有的 Action
+//
+//	CNAME .             -> NXDOMAIN  => ActionBlock, policy "nxdomain"
+//	CNAME *.             -> NODATA    => ActionBlock, policy "nodata" (暂不区分 NODATA, 见 chunk1-1 的 rewrite 动作)
+//	CNAME rpz-passthru.  -> 放行      => ActionAllow, policy "passthru"
+//	CNAME rpz-drop.      -> 丢弃      => ActionBlock, policy "drop" (引擎层面无法真正丢包, 退化为拒绝应答)
+//	CNAME rpz-tcp-only.  -> 仅 TCP    => ActionLog, policy "tcp-only" (暂不支持强制 TCP 重试, 仅记录)
+//	其它                 -> 域名重写  => ActionLog, policy "cname-rewrite" (跨域名 CNAME 重写需要 chunk1-1 的 rewrite 支持)
+func classifyCNAMETarget(target string) (action filter.Action, policy string, ok bool) {
+	normalized := strings.ToLower(strings.TrimSuffix(target, "."))
+
+	switch normalized {
+	case "":
+		return filter.ActionBlock, "nxdomain", true
+	case "*":
+		return filter.ActionBlock, "nodata", true
+	case "rpz-passthru":
+		return filter.ActionAllow, "passthru", true
+	case "rpz-drop":
+		return filter.ActionBlock, "drop", true
+	case "rpz-tcp-only":
+		return filter.ActionLog, "tcp-only", true
+	default:
+		return filter.ActionLog, "cname-rewrite", true
+	}
+}
+
+// rulePolicySep 分隔规则 ID 里的 "<前缀><序号>" 与其触发策略类型, 见 Loader.install
+const rulePolicySep = "~"
+
+// HitCounter 实现 filter.Sink, 按区域统计命中次数 (xdp_dns_rpz_hits_total{zone=...}) 以及
+// 按策略类型细分的命中次数 (xdp_dns_rpz_policy_hits_total{zone,policy=...})
+type HitCounter struct {
+	zone   string
+	prefix string
+}
+
+// NewHitCounter 创建一个只对 zone 对应规则计数的 Sink, 通过规则 ID 前缀识别归属区域
+func NewHitCounter(zone, ruleIDPrefix string) *HitCounter {
+	return &HitCounter{zone: zone, prefix: ruleIDPrefix}
+}
+
+// OnDecision 实现 filter.Sink
+func (c *HitCounter) OnDecision(d filter.Decision) {
+	if d.RuleID == "" || !strings.HasPrefix(d.RuleID, c.prefix) {
+		return
+	}
+	metrics.IncRPZHit(c.zone)
+
+	policy := "unknown"
+	if idx := strings.LastIndex(d.RuleID, rulePolicySep); idx >= 0 {
+		policy = d.RuleID[idx+len(rulePolicySep):]
+	}
+	metrics.IncRPZPolicyHit(c.zone, policy)
+}
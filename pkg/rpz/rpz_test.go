@@ -0,0 +1,101 @@
+package rpz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"xdp-dns/pkg/filter"
+)
+
+const sampleZone = `
+; sample Spamhaus-style RPZ zone
+$TTL 300
+malware.example.com.rpz CNAME .
+phish.example.com.rpz CNAME rpz-passthru.
+safe.example.com.rpz CNAME rpz-drop.
+rewrite.example.com.rpz A 10.0.0.1
+8.8.8.8.rpz-ip CNAME .
+`
+
+func TestParseZone_ClassifiesEntries(t *testing.T) {
+	result, err := ParseZone(strings.NewReader(sampleZone), "rpz")
+	if err != nil {
+		t.Fatalf("ParseZone() error = %v", err)
+	}
+
+	if result.SkippedTriggers != 1 {
+		t.Errorf("SkippedTriggers = %d, want 1 (the rpz-ip entry)", result.SkippedTriggers)
+	}
+
+	want := map[string]filter.Action{
+		"malware.example.com": filter.ActionBlock,
+		"phish.example.com":   filter.ActionAllow,
+		"safe.example.com":    filter.ActionBlock,
+		"rewrite.example.com": filter.ActionRedirect,
+	}
+
+	if len(result.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(result.Entries), len(want))
+	}
+
+	for _, e := range result.Entries {
+		action, ok := want[e.Name]
+		if !ok {
+			t.Errorf("unexpected entry for %s", e.Name)
+			continue
+		}
+		if e.Action != action {
+			t.Errorf("entry %s: action = %v, want %v", e.Name, e.Action, action)
+		}
+	}
+}
+
+func TestLoader_RefreshInstallsRulesAndHonorsETag(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("malware.example.com.rpz CNAME .\n"))
+	}))
+	defer server.Close()
+
+	engine, err := filter.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	loader := NewLoader(engine, "rpz", server.URL)
+
+	changed, err := loader.Refresh(t.Context())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if !changed {
+		t.Fatal("Refresh() changed = false on first fetch, want true")
+	}
+
+	checkResult, err := engine.CheckDomain("malware.example.com", 0, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if checkResult.Action != filter.ActionBlock {
+		t.Errorf("CheckDomain() action = %v, want ActionBlock", checkResult.Action)
+	}
+
+	changed, err = loader.Refresh(t.Context())
+	if err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+	if changed {
+		t.Error("second Refresh() changed = true, want false (ETag should match)")
+	}
+	if requests != 2 {
+		t.Errorf("server received %d requests, want 2", requests)
+	}
+}
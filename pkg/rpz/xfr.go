@@ -0,0 +1,348 @@
+// xfr.go 实现 RFC 5936 AXFR 区域传送 (TCP), 并以 SOA 序列号轮询判断区域是否需要刷新,
+// 作为 HTTPS + ETag (loader.go) 之外的另一种区域分发方式, 便于直接对接权威/隐藏主服务器。
+//
+// RFC 1995 IXFR 增量传送需要维护上一次已知的 SOA 序列号并解析差异记录序列 (一对 SOA 之间
+// 夹着被删除/新增的 RR), 当前未实现: 这里统一退化为每次变化都做一次完整 AXFR, 调用方感知
+// 不到差异, 只是传输量比真正的增量传送更大。SOASerial 轮询已经避免了区域未变化时的重复传送。
+package rpz
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"xdp-dns/pkg/filter"
+)
+
+const (
+	rrTypeA     uint16 = 1
+	rrTypeCNAME uint16 = 5
+	rrTypeSOA   uint16 = 6
+	rrTypeAAAA  uint16 = 28
+	rrTypeAXFR  uint16 = 252
+	rrClassIN   uint16 = 1
+)
+
+// XFRTransport 通过 AXFR-over-TCP 从权威/隐藏主服务器拉取一个 RPZ 区域
+type XFRTransport struct {
+	Addr   string // 权威服务器地址 "host:port"
+	Zone   string
+	Dialer net.Dialer
+}
+
+// NewXFRTransport 创建一个 AXFR 传输, addr 形如 "10.0.0.1:53"
+func NewXFRTransport(addr, zone string) *XFRTransport {
+	return &XFRTransport{Addr: addr, Zone: zone}
+}
+
+// SOASerial 查询区域当前的 SOA 序列号, 用于在发起完整 AXFR 前判断区域是否已更新
+func (x *XFRTransport) SOASerial(ctx context.Context) (uint32, error) {
+	conn, err := x.Dialer.DialContext(ctx, "tcp", x.Addr)
+	if err != nil {
+		return 0, fmt.Errorf("rpz: dial %s for zone %s SOA query: %w", x.Addr, x.Zone, err)
+	}
+	defer conn.Close()
+
+	if err := writeTCPMessage(conn, buildQuery(x.Zone, rrTypeSOA)); err != nil {
+		return 0, fmt.Errorf("rpz: send SOA query for zone %s: %w", x.Zone, err)
+	}
+
+	msg, err := readTCPMessage(conn)
+	if err != nil {
+		return 0, fmt.Errorf("rpz: read SOA response for zone %s: %w", x.Zone, err)
+	}
+
+	rrs, err := parseAnswers(msg)
+	if err != nil {
+		return 0, fmt.Errorf("rpz: parse SOA response for zone %s: %w", x.Zone, err)
+	}
+	for _, rr := range rrs {
+		if rr.rrType == rrTypeSOA {
+			return soaSerial(rr.rdata)
+		}
+	}
+	return 0, fmt.Errorf("rpz: SOA response for zone %s contained no SOA record", x.Zone)
+}
+
+// TransferAXFR 执行一次全量区域传送 (RFC 5936), 把结果转换为可安装进 Engine 的 Entry 列表
+func (x *XFRTransport) TransferAXFR(ctx context.Context) (*ParseResult, error) {
+	conn, err := x.Dialer.DialContext(ctx, "tcp", x.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("rpz: dial %s for zone %s AXFR: %w", x.Addr, x.Zone, err)
+	}
+	defer conn.Close()
+
+	if err := writeTCPMessage(conn, buildQuery(x.Zone, rrTypeAXFR)); err != nil {
+		return nil, fmt.Errorf("rpz: send AXFR query for zone %s: %w", x.Zone, err)
+	}
+
+	result := &ParseResult{}
+	suffix := "." + strings.TrimSuffix(x.Zone, ".")
+	soaSeen := 0
+
+	for {
+		msg, err := readTCPMessage(conn)
+		if err != nil {
+			return nil, fmt.Errorf("rpz: read AXFR message for zone %s: %w", x.Zone, err)
+		}
+
+		rrs, err := parseAnswers(msg)
+		if err != nil {
+			return nil, fmt.Errorf("rpz: parse AXFR message for zone %s: %w", x.Zone, err)
+		}
+
+		for _, rr := range rrs {
+			if rr.rrType == rrTypeSOA {
+				soaSeen++
+				// RFC 5936: 传送以 SOA 开始并以同一条 SOA 结束, 第二次出现即传送完毕
+				if soaSeen == 2 {
+					return result, nil
+				}
+				continue
+			}
+
+			owner := strings.TrimSuffix(strings.TrimSuffix(rr.name, suffix), ".")
+			trigger, name := classifyTrigger(owner)
+			if trigger != TriggerQNAME {
+				result.SkippedTriggers++
+				continue
+			}
+
+			switch rr.rrType {
+			case rrTypeCNAME:
+				target, err := decodeNameAt(rr.message, rr.rdataOffset)
+				if err != nil {
+					continue
+				}
+				action, policy, ok := classifyCNAMETarget(target)
+				if !ok {
+					continue
+				}
+				result.Entries = append(result.Entries, Entry{Trigger: trigger, Name: name, Action: action, Policy: policy})
+
+			case rrTypeA, rrTypeAAAA:
+				ip := net.IP(rr.rdata)
+				if ip == nil {
+					continue
+				}
+				result.Entries = append(result.Entries, Entry{
+					Trigger: trigger, Name: name, Action: filter.ActionRedirect, Policy: "rewrite", RedirectIP: ip, TTL: rr.ttl,
+				})
+			}
+		}
+
+		if soaSeen >= 2 {
+			return result, nil
+		}
+	}
+}
+
+// rawRR 是一条从 AXFR 消息流里解析出的资源记录, 保留其在原始消息中的绝对偏移以便
+// 解析 RDATA 内部可能存在的域名压缩指针 (压缩指针的偏移量是相对整条消息的)
+type rawRR struct {
+	name        string
+	rrType      uint16
+	ttl         uint32
+	rdata       []byte
+	rdataOffset int
+	message     []byte
+}
+
+// buildQuery 构造一个只含单条 Question 的标准 DNS 查询消息 (不含长度前缀)
+func buildQuery(zone string, qtype uint16) []byte {
+	name := encodeQueryName(zone)
+	msg := make([]byte, 0, 12+len(name)+4)
+	msg = append(msg, 0, 0)             // ID, 查询方不关心匹配, 传送是严格按序的单连接
+	msg = append(msg, 0x01, 0x00)       // Flags: RD
+	msg = append(msg, 0, 1)             // QDCOUNT=1
+	msg = append(msg, 0, 0, 0, 0, 0, 0) // ANCOUNT/NSCOUNT/ARCOUNT=0
+	msg = append(msg, name...)
+	var qtypeBuf, qclassBuf [2]byte
+	binary.BigEndian.PutUint16(qtypeBuf[:], qtype)
+	binary.BigEndian.PutUint16(qclassBuf[:], rrClassIN)
+	msg = append(msg, qtypeBuf[:]...)
+	msg = append(msg, qclassBuf[:]...)
+	return msg
+}
+
+// encodeQueryName 把点分域名编码为未压缩的标签序列, 以零长度标签结尾
+func encodeQueryName(name string) []byte {
+	name = strings.TrimSuffix(name, ".")
+	var buf []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+	}
+	buf = append(buf, 0)
+	return buf
+}
+
+// writeTCPMessage 按 RFC 1035 §4.2.2 写入一个带 2 字节长度前缀的消息
+func writeTCPMessage(conn net.Conn, msg []byte) error {
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(msg)))
+	if _, err := conn.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := conn.Write(msg)
+	return err
+}
+
+// readTCPMessage 读取一条带 2 字节长度前缀的消息
+func readTCPMessage(conn net.Conn) ([]byte, error) {
+	var length [2]byte
+	if _, err := readFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := readFull(conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func readFull(conn net.Conn, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := conn.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// parseAnswers 解析消息头部、跳过 Question 部分, 返回 Answer 部分的全部资源记录
+func parseAnswers(msg []byte) ([]rawRR, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("message too short")
+	}
+	qdCount := binary.BigEndian.Uint16(msg[4:6])
+	anCount := binary.BigEndian.Uint16(msg[6:8])
+
+	offset := 12
+	for i := uint16(0); i < qdCount; i++ {
+		_, newOffset, err := decodeNameAtWithOffset(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = newOffset + 4 // QTYPE + QCLASS
+	}
+
+	rrs := make([]rawRR, 0, anCount)
+	for i := uint16(0); i < anCount; i++ {
+		name, newOffset, err := decodeNameAtWithOffset(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = newOffset
+		if offset+10 > len(msg) {
+			return nil, fmt.Errorf("truncated resource record")
+		}
+		rrType := binary.BigEndian.Uint16(msg[offset : offset+2])
+		ttl := binary.BigEndian.Uint32(msg[offset+4 : offset+8])
+		rdLength := binary.BigEndian.Uint16(msg[offset+8 : offset+10])
+		rdataOffset := offset + 10
+		rdataEnd := rdataOffset + int(rdLength)
+		if rdataEnd > len(msg) {
+			return nil, fmt.Errorf("truncated RDATA")
+		}
+
+		rrs = append(rrs, rawRR{
+			name:        strings.ToLower(name),
+			rrType:      rrType,
+			ttl:         ttl,
+			rdata:       msg[rdataOffset:rdataEnd],
+			rdataOffset: rdataOffset,
+			message:     msg,
+		})
+		offset = rdataEnd
+	}
+	return rrs, nil
+}
+
+// decodeNameAt 解码消息 message 中 offset 处的域名 (支持 RFC 1035 §4.1.4 压缩指针),
+// 仅返回域名本身, 供读取 RDATA 内嵌域名 (如 CNAME target) 时使用
+func decodeNameAt(message []byte, offset int) (string, error) {
+	name, _, err := decodeNameAtWithOffset(message, offset)
+	return name, err
+}
+
+// decodeNameAtWithOffset 同 decodeNameAt, 并额外返回名称结束后(未跟随指针时)的偏移量
+func decodeNameAtWithOffset(message []byte, offset int) (string, int, error) {
+	var labels []string
+	visited := make(map[int]bool)
+	originalOffset := offset
+	jumped := false
+
+	for {
+		if offset >= len(message) {
+			return "", 0, fmt.Errorf("name extends past end of message")
+		}
+		if visited[offset] {
+			return "", 0, fmt.Errorf("compression pointer loop")
+		}
+		visited[offset] = true
+
+		length := int(message[offset])
+		if length == 0 {
+			if !jumped {
+				originalOffset = offset + 1
+			}
+			return strings.Join(labels, "."), originalOffset, nil
+		}
+
+		if length&0xC0 == 0xC0 {
+			if offset+1 >= len(message) {
+				return "", 0, fmt.Errorf("truncated compression pointer")
+			}
+			pointer := int(binary.BigEndian.Uint16(message[offset:offset+2]) & 0x3FFF)
+			if !jumped {
+				originalOffset = offset + 2
+			}
+			jumped = true
+			offset = pointer
+			continue
+		}
+
+		if offset+1+length > len(message) {
+			return "", 0, fmt.Errorf("truncated label")
+		}
+		labels = append(labels, string(message[offset+1:offset+1+length]))
+		offset += 1 + length
+	}
+}
+
+// soaSerial 从 SOA RDATA 中取出序列号字段 (MNAME、RNAME 之后的第一个 32 位无符号整数)
+func soaSerial(rdata []byte) (uint32, error) {
+	// SOA RDATA: MNAME, RNAME, SERIAL(4), REFRESH(4), RETRY(4), EXPIRE(4), MINIMUM(4)
+	// MNAME/RNAME 可能以压缩指针结尾; 指针总是一个名字的最后一部分, 所以跳过名字时
+	// 遇到指针只需前进 2 字节, 不必跟随指针去解析它指向的内容。
+	offset := 0
+	for i := 0; i < 2; i++ {
+		for {
+			if offset >= len(rdata) {
+				return 0, fmt.Errorf("truncated SOA RDATA")
+			}
+			length := int(rdata[offset])
+			if length&0xC0 == 0xC0 {
+				offset += 2
+				break
+			}
+			offset++
+			if length == 0 {
+				break
+			}
+			offset += length
+		}
+	}
+	if offset+4 > len(rdata) {
+		return 0, fmt.Errorf("truncated SOA RDATA")
+	}
+	return binary.BigEndian.Uint32(rdata[offset : offset+4]), nil
+}
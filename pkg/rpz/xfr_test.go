@@ -0,0 +1,185 @@
+package rpz
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"xdp-dns/pkg/filter"
+)
+
+// buildRRMessage 构造一个只含 Answer 部分的 DNS 消息 (AXFR/IXFR 响应使用的惯例), 不含
+// Question、不使用域名压缩, 用于测试 parseAnswers/TransferAXFR
+func buildRRMessage(rrs [][]byte) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(rrs)))
+	for _, rr := range rrs {
+		msg = append(msg, rr...)
+	}
+	return msg
+}
+
+func encodeTestName(name string) []byte {
+	var buf []byte
+	for _, label := range splitLabels(name) {
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	buf = append(buf, 0)
+	return buf
+}
+
+func splitLabels(name string) []string {
+	if name == "" {
+		return nil
+	}
+	var labels []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			labels = append(labels, name[start:i])
+			start = i + 1
+		}
+	}
+	labels = append(labels, name[start:])
+	return labels
+}
+
+func buildRR(name string, rrType uint16, ttl uint32, rdata []byte) []byte {
+	buf := encodeTestName(name)
+	var head [8]byte
+	binary.BigEndian.PutUint16(head[0:2], rrType)
+	binary.BigEndian.PutUint16(head[2:4], rrClassIN)
+	binary.BigEndian.PutUint32(head[4:8], ttl)
+	var rdLen [2]byte
+	binary.BigEndian.PutUint16(rdLen[:], uint16(len(rdata)))
+	buf = append(buf, head[:]...)
+	buf = append(buf, rdLen[:]...)
+	buf = append(buf, rdata...)
+	return buf
+}
+
+func buildSOARData() []byte {
+	var rdata []byte
+	rdata = append(rdata, encodeTestName("ns1.example.org")...)
+	rdata = append(rdata, encodeTestName("hostmaster.example.org")...)
+	var tail [20]byte
+	binary.BigEndian.PutUint32(tail[0:4], 42) // serial
+	rdata = append(rdata, tail[:]...)
+	return rdata
+}
+
+// startFakeAXFRServer 启动一个假权威服务器, 每条连接只应答一条查询: SOA 查询得到只含
+// SOA 记录的消息, 其它查询 (AXFR) 得到完整传送 (SOA, CNAME, A, SOA)
+func startFakeAXFRServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	soa := buildRR("rpz.example.org", rrTypeSOA, 3600, buildSOARData())
+	cname := buildRR("malware.example.com.rpz.example.org", rrTypeCNAME, 300, encodeTestName("."))
+	a := buildRR("redirect.example.com.rpz.example.org", rrTypeA, 300, net.ParseIP("10.0.0.9").To4())
+	full := buildRRMessage([][]byte{soa, cname, a, soa})
+	soaOnly := buildRRMessage([][]byte{soa})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				query, err := readTCPMessage(conn)
+				if err != nil {
+					return
+				}
+				qtype := binary.BigEndian.Uint16(query[len(query)-4 : len(query)-2])
+				if qtype == rrTypeSOA {
+					writeTCPMessage(conn, soaOnly)
+				} else {
+					writeTCPMessage(conn, full)
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestXFRTransport_SOASerial(t *testing.T) {
+	addr := startFakeAXFRServer(t)
+	x := NewXFRTransport(addr, "rpz.example.org")
+
+	serial, err := x.SOASerial(t.Context())
+	if err != nil {
+		t.Fatalf("SOASerial() error = %v", err)
+	}
+	if serial != 42 {
+		t.Errorf("SOASerial() = %d, want 42", serial)
+	}
+}
+
+func TestXFRTransport_TransferAXFR(t *testing.T) {
+	addr := startFakeAXFRServer(t)
+	x := NewXFRTransport(addr, "rpz.example.org")
+
+	result, err := x.TransferAXFR(t.Context())
+	if err != nil {
+		t.Fatalf("TransferAXFR() error = %v", err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (SOA records excluded)", len(result.Entries))
+	}
+
+	byName := map[string]Entry{}
+	for _, e := range result.Entries {
+		byName[e.Name] = e
+	}
+
+	block, ok := byName["malware.example.com"]
+	if !ok || block.Action != filter.ActionBlock || block.Policy != "nxdomain" {
+		t.Errorf("malware.example.com entry = %+v, want ActionBlock/nxdomain", block)
+	}
+
+	redirect, ok := byName["redirect.example.com"]
+	if !ok || redirect.Action != filter.ActionRedirect || redirect.RedirectIP.String() != "10.0.0.9" {
+		t.Errorf("redirect.example.com entry = %+v, want ActionRedirect to 10.0.0.9", redirect)
+	}
+}
+
+func TestLoader_RefreshXFR_SkipsWhenSerialUnchanged(t *testing.T) {
+	addr := startFakeAXFRServer(t)
+	engine, err := filter.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	loader := NewAXFRLoader(engine, "rpz.example.org", addr)
+
+	changed, err := loader.Refresh(t.Context())
+	if err != nil {
+		t.Fatalf("first Refresh() error = %v", err)
+	}
+	if !changed {
+		t.Fatal("first Refresh() changed = false, want true")
+	}
+
+	changed, err = loader.Refresh(t.Context())
+	if err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+	if changed {
+		t.Error("second Refresh() changed = true, want false (SOA serial unchanged)")
+	}
+
+	result, err := engine.CheckDomain("malware.example.com", 0, nil)
+	if err != nil {
+		t.Fatalf("CheckDomain() error = %v", err)
+	}
+	if result.Action != filter.ActionBlock {
+		t.Errorf("CheckDomain() action = %v, want ActionBlock", result.Action)
+	}
+}
@@ -0,0 +1,162 @@
+// Package tcpserver 提供一个独立于 AF_XDP 快路径之外的标准 TCP:53 监听器
+//
+// 收到 TC=1 截断响应的客户端会按 RFC 1035/7766 改用 TCP 向同一服务器重试, AXFR
+// 风格的工作负载也只能走 TCP。本包把每条连接上 2 字节长度前缀帧里的查询字节直接
+// 交给与 AF_XDP Worker 共用的同一个 hybrid.Processor, 使 TCP 重试和 XDP 快路径
+// 走完全相同的过滤/转发决策 (相比之下 pkg/dns/tcp 是更早期、面向 filter.Engine
+// 直接交互的 DoT/DoH 回落通道, 两者并存, 调用方按需选用)。
+package tcpserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"xdp-dns/pkg/dns/hybrid"
+	"xdp-dns/pkg/metrics"
+)
+
+// 单条 TCP 消息的长度前缀是 2 字节, 因此最大可达 65535 字节
+const maxMessageSize = 65535
+
+// ErrMessageTooLarge 消息长度前缀超出协议允许的范围
+var ErrMessageTooLarge = errors.New("tcpserver: message exceeds maximum TCP frame size")
+
+// Server 标准 TCP:53 DNS 监听器
+type Server struct {
+	listener    net.Listener
+	processor   *hybrid.Processor
+	idleTimeout time.Duration
+	sem         chan struct{} // 有界并发信号量, 连接数达到上限时直接拒绝新连接, 防御 slow-loris
+}
+
+// NewServer 创建新的 TCP:53 服务
+//
+// idleTimeout 是连接空闲超时, <=0 时退化为 30s; maxConns 是同时处理的连接数上限,
+// <=0 时退化为 1024, 超过上限的新连接会被立即关闭而不是无限制排队等待。
+func NewServer(processor *hybrid.Processor, idleTimeout time.Duration, maxConns int) *Server {
+	if idleTimeout <= 0 {
+		idleTimeout = 30 * time.Second
+	}
+	if maxConns <= 0 {
+		maxConns = 1024
+	}
+	return &Server{
+		processor:   processor,
+		idleTimeout: idleTimeout,
+		sem:         make(chan struct{}, maxConns),
+	}
+}
+
+// ListenAndServe 在 addr (通常是 ":53") 上监听并处理连接, 阻塞直到 Close 被调用
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	log.Printf("TCP:53 listener started on %s", addr)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("tcpserver: accept error: %v", err)
+			continue
+		}
+
+		select {
+		case s.sem <- struct{}{}:
+			go s.handleConn(conn)
+		default:
+			conn.Close()
+		}
+	}
+}
+
+// Close 停止监听, 不影响已经建立的连接
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn 处理单条连接上的多条流水线查询 (RFC 7766 §8 pipelining)
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		<-s.sem
+	}()
+
+	metrics.IncTCPConnections()
+	defer metrics.DecTCPConnections()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+
+		query, err := readMessage(conn)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("tcpserver: read error from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		metrics.IncTCPQueries()
+
+		result, err := s.processor.Process(query)
+		if err != nil {
+			continue // 解析失败的报文直接丢弃, 不回任何响应
+		}
+		if result.Response == nil {
+			// ActionAllow/ActionLog 在 hybrid.Processor 里就是"不构建响应" (XDP 快路径下
+			// 放行的包由内核态继续正常路由, 本身不经过这里); 独立 TCP 监听器没有等价的
+			// 放行路径可以转交, 这里如实保留这个限制而不是伪造一个响应, 客户端会按正常
+			// 超时重试/切换到其它解析器。
+			continue
+		}
+
+		if err := writeMessage(conn, result.Response); err != nil {
+			log.Printf("tcpserver: write error to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// readMessage 读取一条 2 字节长度前缀 + 负载的 DNS-over-TCP 消息
+func readMessage(r io.Reader) ([]byte, error) {
+	var lengthBuf [2]byte
+	if _, err := io.ReadFull(r, lengthBuf[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint16(lengthBuf[:])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// writeMessage 以 2 字节长度前缀写出一条 DNS-over-TCP 消息
+func writeMessage(w io.Writer, payload []byte) error {
+	if len(payload) > maxMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	var lengthBuf [2]byte
+	binary.BigEndian.PutUint16(lengthBuf[:], uint16(len(payload)))
+
+	if _, err := w.Write(lengthBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
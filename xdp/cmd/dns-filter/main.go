@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -15,17 +17,39 @@ import (
 	"xdp-dns/internal/worker"
 	"xdp-dns/pkg/config"
 	"xdp-dns/pkg/dns"
+	"xdp-dns/pkg/dns/hybrid"
+	"xdp-dns/pkg/dns/tcp"
+	"xdp-dns/pkg/dnscache"
+	"xdp-dns/pkg/dnstap"
 	"xdp-dns/pkg/filter"
+	"xdp-dns/pkg/listener"
 	"xdp-dns/pkg/metrics"
+	"xdp-dns/pkg/rpz"
+	"xdp-dns/pkg/tcpserver"
 	"xdp-dns/xdp"
 )
 
+// defaultRPZPollInterval 是 RPZSourceConfig.PollInterval 未设置时使用的默认轮询周期
+const defaultRPZPollInterval = 10 * time.Minute
+
 var (
-	configPath = flag.String("config", "configs/config.yaml", "Path to config file")
-	version    = flag.Bool("version", false, "Show version")
+	configPath   = flag.String("config", "configs/config.yaml", "Path to config file")
+	version      = flag.Bool("version", false, "Show version")
 	buildVersion = "dev"
 )
 
+// newDnstapSink 按配置构造 dnstap 的 file 或 socket sink
+func newDnstapSink(cfg config.DnstapConfig) (dnstap.Sink, error) {
+	switch cfg.Sink {
+	case "socket":
+		return dnstap.NewSocketSink(cfg.Network, cfg.Address)
+	case "file", "":
+		return dnstap.NewFileSink(cfg.Path, cfg.MaxBytes)
+	default:
+		return nil, fmt.Errorf("unknown dnstap sink type %q", cfg.Sink)
+	}
+}
+
 func main() {
 	flag.Parse()
 
@@ -98,6 +122,83 @@ func main() {
 		log.Fatalf("Failed to init filter engine: %v", err)
 	}
 	log.Printf("Filter engine initialized with %d rules", len(filterEngine.GetRules()))
+	defer filterEngine.Close()
+
+	// 启动上下文 (供下面的规则来源轮询、RPZ、规则热重载、metrics 和 Worker 池等后台 goroutine 使用)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// 接入 dnstap 决策日志 sink (采样比例由规则文件的 log_sampling 字段控制)
+	if cfg.Dnstap.Enabled {
+		sink, err := newDnstapSink(cfg.Dnstap)
+		if err != nil {
+			log.Printf("dnstap: failed to init sink: %v", err)
+		} else {
+			filterEngine.AddSink(dnstap.NewEngineSink(sink))
+			log.Printf("dnstap sink enabled (%s)", cfg.Dnstap.Sink)
+		}
+	}
+
+	// 注册可插拔规则来源 (hosts/AdGuard/dnsmasq/域名列表), 并启动周期轮询
+	for _, rs := range cfg.RuleSources {
+		source, err := filter.NewRuleSource(rs.Type, rs.Path)
+		if err != nil {
+			log.Printf("rule source %s: failed to init: %v", rs.Path, err)
+			continue
+		}
+		filterEngine.AddRuleSource(source, rs.PollInterval)
+		log.Printf("Rule source %s (%s) registered", rs.Path, rs.Type)
+	}
+	go filterEngine.PollRuleSources(ctx)
+
+	// 装载 RPZ (Response Policy Zone) 区域, 周期性拉取并安装为 filter.Rule。
+	// AXFRAddr 非空时走 SOA 序列号轮询 + 全量 AXFR (不做 IXFR 增量传送), 否则走
+	// HTTPS+ETag 拉取; 两种 Loader 都会
+	// 自动挂一个按区域统计命中的 HitCounter sink (xdp_dns_rpz_hits_total{zone=...})
+	for _, rz := range cfg.RPZSources {
+		var loader *rpz.Loader
+		if rz.AXFRAddr != "" {
+			loader = rpz.NewAXFRLoader(filterEngine, rz.Zone, rz.AXFRAddr)
+		} else {
+			loader = rpz.NewLoader(filterEngine, rz.Zone, rz.URL)
+		}
+		interval := rz.PollInterval
+		if interval <= 0 {
+			interval = defaultRPZPollInterval
+		}
+		go loader.Run(ctx, interval)
+		log.Printf("RPZ zone %q loader started (interval=%s)", rz.Zone, interval)
+	}
+
+	// 规则文件热重载: RulesPath 被覆盖/替换后自动调用 filterEngine.LoadRules
+	if cfg.RulesHotReload {
+		watcher, err := filter.NewWatcher(filterEngine, cfg.RulesPath)
+		if err != nil {
+			log.Printf("rules watcher: failed to init: %v", err)
+		} else {
+			go watcher.Run(ctx)
+			log.Printf("Rules hot-reload watcher started for %s", cfg.RulesPath)
+		}
+	}
+
+	// 普通 TCP:53 兜底监听: 供收到 TC=1 的客户端重试, 复用与 AF_XDP fast path 相同
+	// 的 hybrid.Processor 决策逻辑
+	if cfg.DNS.TCPFallback.Enabled {
+		processor, err := hybrid.NewProcessor(filterEngine)
+		if err != nil {
+			log.Printf("tcp fallback: failed to init hybrid processor: %v", err)
+		} else {
+			defer processor.Close()
+			processor.SetCache(dnscache.NewCache(int64(cfg.DNS.CacheSize), cfg.DNS.CacheTTL))
+			tcpFallback := tcpserver.NewServer(processor, cfg.DNS.TCPFallback.IdleTimeout, cfg.DNS.TCPFallback.MaxConns)
+			defer tcpFallback.Close()
+			go func(addr string) {
+				if err := tcpFallback.ListenAndServe(addr); err != nil {
+					log.Printf("tcp fallback: listener on %s stopped: %v", addr, err)
+				}
+			}(cfg.DNS.TCPFallback.Listen)
+			log.Printf("TCP:53 fallback listener started on %s", cfg.DNS.TCPFallback.Listen)
+		}
+	}
 
 	// 创建 Worker 池
 	workerPool := worker.NewPool(worker.PoolOptions{
@@ -109,9 +210,6 @@ func main() {
 		Metrics:      metricsCollector,
 	})
 
-	// 启动上下文
-	ctx, cancel := context.WithCancel(context.Background())
-
 	// 启动 metrics 服务器
 	if cfg.Metrics.Enabled {
 		exporter := metrics.NewExporter(metricsCollector, cfg.Metrics.Listen, cfg.Metrics.Path)
@@ -128,6 +226,58 @@ func main() {
 	go workerPool.Start(ctx)
 	log.Printf("Worker pool started with %d workers", cfg.Workers.NumWorkers)
 
+	// 启动加密 DNS 前端监听器 (DoT/DoH/DoQ); DoT 与 DoH 共用同一个 filterEngine 的决策逻辑,
+	// DoQ 只是占位入口, 不处理查询
+	for _, lc := range cfg.DNS.Listeners {
+		switch lc.Protocol {
+		case "dot":
+			// DNS-over-TLS (RFC 7858), 经 pkg/dns/tcp.Server.ListenAndServeTLS
+			cert, err := tls.LoadX509KeyPair(lc.CertFile, lc.KeyFile)
+			if err != nil {
+				log.Printf("DoT: failed to load TLS cert for %s: %v", lc.Listen, err)
+				continue
+			}
+			tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+			if len(lc.ALPN) > 0 {
+				tlsConfig.NextProtos = lc.ALPN
+			}
+			dotServer := tcp.NewServer(filterEngine, 0)
+			defer dotServer.Close()
+			go func(addr string) {
+				if err := dotServer.ListenAndServeTLS(addr, tlsConfig); err != nil {
+					log.Printf("DoT: listener on %s stopped: %v", addr, err)
+				}
+			}(lc.Listen)
+			log.Printf("DoT listener started on %s", lc.Listen)
+
+		case "doh":
+			// DNS-over-HTTPS (RFC 8484), 经 listener.DoHHandler + http.Server
+			dohServer := &http.Server{
+				Addr:    lc.Listen,
+				Handler: listener.NewDoHHandler(filterEngine),
+			}
+			go func(addr string, certFile, keyFile string) {
+				if err := dohServer.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+					log.Printf("DoH: listener on %s stopped: %v", addr, err)
+				}
+			}(lc.Listen, lc.CertFile, lc.KeyFile)
+			defer dohServer.Close()
+			log.Printf("DoH listener started on %s", lc.Listen)
+
+		case "doq":
+			// DNS-over-QUIC (RFC 9250): listener.ListenAndServeDoQ 当前是一个诚实的
+			// 占位实现 (未引入 QUIC 依赖), 记录失败但不影响其余监听器
+			go func(addr string) {
+				if err := listener.ListenAndServeDoQ(addr); err != nil {
+					log.Printf("DoQ: listener on %s unavailable: %v", addr, err)
+				}
+			}(lc.Listen)
+
+		default:
+			log.Printf("Unknown DNS listener protocol %q for %s, skipping", lc.Protocol, lc.Listen)
+		}
+	}
+
 	// 等待信号
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -147,4 +297,3 @@ func main() {
 
 	log.Println("XDP DNS Filter stopped.")
 }
-